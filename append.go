@@ -0,0 +1,107 @@
+package phpserialize
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+)
+
+// Append functions write single php serialized values and are meant
+// for code implementing AppendMarshaler, such as one generated by
+// phpserialize-gen. Floats use default precision of the encoder
+
+// AppendNil appends N;
+func AppendNil(dst []byte) []byte {
+	return append(dst, phpNil...)
+}
+
+// AppendBool appends b:0; or b:1;
+func AppendBool(dst []byte, b bool) []byte {
+	if b {
+		return append(dst, "b:1;"...)
+	}
+	return append(dst, "b:0;"...)
+}
+
+// AppendInt appends i as php integer
+func AppendInt(dst []byte, i int64) []byte {
+	dst = append(dst, integerPrefix...)
+	return append(appendInt(dst, i), ';')
+}
+
+// AppendUint appends u as php integer, or as float when it is
+// above MaxInt64 which php integers can't hold
+func AppendUint(dst []byte, u uint64) []byte {
+	if u > math.MaxInt64 {
+		return AppendFloat(dst, float64(u), 64)
+	}
+	dst = append(dst, integerPrefix...)
+	return append(appendUint(dst, u), ';')
+}
+
+// AppendFloat appends f as php float, bitSize is 32 for float32 values
+func AppendFloat(dst []byte, f float64, bitSize int) []byte {
+	dst = append(dst, "d:"...)
+	return append(appendFloat(dst, f, bitSize, 0), ';')
+}
+
+// AppendString appends s as php string
+func AppendString(dst []byte, s string) []byte {
+	dst = append(dst, "s:"...)
+	dst = appendInt(dst, int64(len(s)))
+	dst = append(dst, ':', '"')
+	dst = append(dst, s...)
+	return append(dst, '"', ';')
+}
+
+// AppendBytes appends b as php string
+func AppendBytes(dst []byte, b []byte) []byte {
+	dst = append(dst, "s:"...)
+	dst = appendInt(dst, int64(len(b)))
+	dst = append(dst, ':', '"')
+	dst = append(dst, b...)
+	return append(dst, '"', ';')
+}
+
+// AppendArrayHeader appends start of array of n elements, elements
+// are written as key value pairs and the array is closed with }
+func AppendArrayHeader(dst []byte, n int) []byte {
+	dst = append(dst, "a:"...)
+	dst = appendInt(dst, int64(n))
+	return append(dst, ':', '{')
+}
+
+// AppendObjectHeader appends start of object of class with n properties,
+// properties are written as key value pairs and the object is closed with }
+func AppendObjectHeader(dst []byte, class string, n int) []byte {
+	dst = append(dst, "O:"...)
+	dst = appendInt(dst, int64(len(class)))
+	dst = append(dst, ':', '"')
+	dst = append(dst, class...)
+	dst = append(dst, '"', ':')
+	dst = appendInt(dst, int64(n))
+	return append(dst, ':', '{')
+}
+
+// Pointers is set of pointers written by code generated by phpserialize-gen.
+// Generated code doesn't write php references, so values reaching the same
+// pointer twice are refused rather than written differently than Marshal does
+type Pointers struct {
+	seen map[interface{}]struct{}
+}
+
+// Add records pointer p and returns error if it was added before
+func (s *Pointers) Add(p interface{}) error {
+	v := reflect.ValueOf(p)
+	if v.Type().Elem().Size() == 0 {
+		return nil
+	}
+	if _, ok := s.seen[p]; ok {
+		return &UnsupportedValueError{Value: v, Str: fmt.Sprintf("%s shared within value, generated code does not write references", v.Type())}
+	}
+	if s.seen == nil {
+		s.seen = make(map[interface{}]struct{})
+	}
+	s.seen[p] = struct{}{}
+	return nil
+}
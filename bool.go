@@ -17,3 +17,35 @@ func (e *Encoder) encodeBool(v reflect.Value) error {
 	e.Write(b)
 	return nil
 }
+
+func (d *Decoder) readBool() (bool, error) {
+	if err := d.expectPrefix('b'); err != nil {
+		return false, err
+	}
+	if d.off >= len(d.data) {
+		return false, errUnexpectedEnd
+	}
+	c := d.data[d.off]
+	if c != '0' && c != '1' {
+		return false, d.syntaxError("0 or 1")
+	}
+	d.off++
+	return c == '1', d.expect(';')
+}
+
+func (d *Decoder) decodeBool(v reflect.Value) error {
+	b, err := d.readBool()
+	if err != nil {
+		return err
+	}
+	v = indirect(v, false)
+	switch {
+	case isBool(v):
+		v.SetBool(b)
+	case isEmptyInterface(v):
+		v.Set(reflect.ValueOf(b))
+	default:
+		return d.typeError("bool", v)
+	}
+	return nil
+}
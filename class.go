@@ -0,0 +1,53 @@
+package phpserialize
+
+import (
+	"reflect"
+	"sync"
+)
+
+// ClassNamer is implemented by structs which are serialized as php
+// objects of the returned class instead of arrays
+type ClassNamer interface {
+	PHPClassName() string
+}
+
+const stdClass = "stdClass"
+
+var (
+	classNamerType = reflect.TypeOf((*ClassNamer)(nil)).Elem()
+	classRegistry  sync.Map
+)
+
+// RegisterClass makes decoder create value of the same type as v for php
+// objects of given class when decoding into interface{}
+func RegisterClass(class string, v interface{}) {
+	t := reflect.TypeOf(v)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	classRegistry.Store(class, t)
+}
+
+func registeredClass(class string) (reflect.Type, bool) {
+	t, ok := classRegistry.Load(class)
+	if !ok {
+		return nil, false
+	}
+	return t.(reflect.Type), true
+}
+
+func isClassNamerType(t reflect.Type) bool {
+	return t.Implements(classNamerType) || reflect.PtrTo(t).Implements(classNamerType)
+}
+
+// className returns php class name of struct v or empty string
+// if it is serialized as array
+func className(v reflect.Value) string {
+	switch {
+	case v.Type().Implements(classNamerType):
+		return v.Interface().(ClassNamer).PHPClassName()
+	case reflect.PtrTo(v.Type()).Implements(classNamerType):
+		return addressable(v).Addr().Interface().(ClassNamer).PHPClassName()
+	}
+	return ""
+}
@@ -0,0 +1,651 @@
+// Command phpserialize-gen generates AppendPHP, MarshalPHP and UnmarshalPHP
+// methods writing and reading structs in php serialize format without
+// reflection.
+//
+// Usage:
+//
+//	//go:generate phpserialize-gen -type=User,Address
+//
+// Methods are generated for listed struct types or for all structs
+// declared in the file when -type is omitted, output goes to file
+// named like the input with _phpgen.go suffix unless -output is set.
+//
+// Fields may be bool, integers, floats, string, []byte, slices of those
+// and structs having AppendPHP method, pointers to and slices of them.
+// Fields are named by php and json tags and support omitempty, protected,
+// private and array options. Output is the same as Marshal with default
+// options writes, encoder options don't apply to generated methods. Struct
+// types with PHPClassName method are written as objects of that class
+// whether the method has pointer receiver or not, as Marshal does.
+// Generated code doesn't write php references, values reaching the same
+// pointer twice are refused with an error instead.
+//
+// UnmarshalPHP decodes values the way Unmarshal does, except for php
+// references which are refused as they point outside of data it gets.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	types := flag.String("type", "", "comma separated list of struct types, all structs of the file by default")
+	output := flag.String("output", "", "output file name, <file>_phpgen.go by default")
+	flag.Parse()
+	file := flag.Arg(0)
+	if file == "" {
+		file = os.Getenv("GOFILE")
+	}
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "usage: phpserialize-gen [-type T,...] [-output file] file.go")
+		os.Exit(2)
+	}
+	var names []string
+	if *types != "" {
+		names = strings.Split(*types, ",")
+	}
+	src, err := generate(file, names)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "phpserialize-gen:", err)
+		os.Exit(1)
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(file, ".go") + "_phpgen.go"
+	}
+	if err := ioutil.WriteFile(*output, src, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "phpserialize-gen:", err)
+		os.Exit(1)
+	}
+}
+
+// kind of value generated code writes
+type kind int
+
+const (
+	kindBool kind = iota
+	kindInt
+	kindUint
+	kindFloat32
+	kindFloat64
+	kindString
+	kindBytes
+	kindStruct
+	kindPtr
+	kindSlice
+)
+
+var basicKinds = map[string]kind{
+	"bool":    kindBool,
+	"int":     kindInt,
+	"int8":    kindInt,
+	"int16":   kindInt,
+	"int32":   kindInt,
+	"int64":   kindInt,
+	"rune":    kindInt,
+	"uint":    kindUint,
+	"uint8":   kindUint,
+	"byte":    kindUint,
+	"uint16":  kindUint,
+	"uint32":  kindUint,
+	"uint64":  kindUint,
+	"float32": kindFloat32,
+	"float64": kindFloat64,
+	"string":  kindString,
+}
+
+type fieldType struct {
+	kind kind
+	name string
+	elem *fieldType
+}
+
+type field struct {
+	goName    string
+	name      string
+	tagged    bool
+	omitEmpty bool
+	protected bool
+	private   bool
+	typ       *fieldType
+}
+
+// pkgInfo holds declarations of the package generated code is added to
+type pkgInfo struct {
+	name string
+	// generated holds types methods are generated for
+	generated map[string]bool
+	structs   map[string]*ast.StructType
+	order     map[string]int
+	methods   map[string]map[string]bool
+	fileDecl  map[string]string
+}
+
+func generate(file string, names []string) ([]byte, error) {
+	pkg, err := parsePackage(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		base := filepath.Base(file)
+		for name, f := range pkg.fileDecl {
+			if f == base {
+				names = append(names, name)
+			}
+		}
+		sort.Slice(names, func(i, j int) bool {
+			return pkg.order[names[i]] < pkg.order[names[j]]
+		})
+	}
+	// types methods are generated for can be used as fields of each other
+	for _, name := range names {
+		if pkg.structs[name] == nil {
+			return nil, fmt.Errorf("%s is not a struct type", name)
+		}
+		if pkg.methods[name] == nil {
+			pkg.methods[name] = map[string]bool{}
+		}
+		pkg.methods[name]["AppendPHP"] = true
+		pkg.generated[name] = true
+	}
+	var buf bytes.Buffer
+	buf.WriteString("// Code generated by phpserialize-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&buf, "package %s\n\nimport \"github.com/dennor/phpserialize\"\n", pkg.name)
+	for _, name := range names {
+		g := &generator{buf: &buf, pkg: pkg, typ: name}
+		if err := g.generate(); err != nil {
+			return nil, err
+		}
+	}
+	return format.Source(buf.Bytes())
+}
+
+func parsePackage(file string) (*pkgInfo, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, filepath.Dir(file), func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go") && !strings.HasSuffix(fi.Name(), "_phpgen.go")
+	}, 0)
+	if err != nil {
+		return nil, err
+	}
+	info := &pkgInfo{
+		generated: map[string]bool{},
+		structs:   map[string]*ast.StructType{},
+		order:     map[string]int{},
+		methods:   map[string]map[string]bool{},
+		fileDecl:  map[string]string{},
+	}
+	base := filepath.Base(file)
+	for name, p := range pkgs {
+		if _, ok := p.Files[filepath.Join(filepath.Dir(file), base)]; !ok {
+			continue
+		}
+		info.name = name
+		for path, f := range p.Files {
+			for _, decl := range f.Decls {
+				switch decl := decl.(type) {
+				case *ast.GenDecl:
+					for _, spec := range decl.Specs {
+						ts, ok := spec.(*ast.TypeSpec)
+						if !ok {
+							continue
+						}
+						if st, ok := ts.Type.(*ast.StructType); ok {
+							info.structs[ts.Name.Name] = st
+							info.order[ts.Name.Name] = int(ts.Pos())
+							info.fileDecl[ts.Name.Name] = filepath.Base(path)
+						}
+					}
+				case *ast.FuncDecl:
+					if decl.Recv == nil || len(decl.Recv.List) != 1 {
+						continue
+					}
+					recv := decl.Recv.List[0].Type
+					if star, ok := recv.(*ast.StarExpr); ok {
+						recv = star.X
+					}
+					if id, ok := recv.(*ast.Ident); ok {
+						if info.methods[id.Name] == nil {
+							info.methods[id.Name] = map[string]bool{}
+						}
+						info.methods[id.Name][decl.Name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	if info.name == "" {
+		return nil, fmt.Errorf("no package found for %s", file)
+	}
+	return info, nil
+}
+
+type generator struct {
+	buf *bytes.Buffer
+	pkg *pkgInfo
+	typ string
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(g.buf, format, args...)
+}
+
+func (g *generator) generate() error {
+	fields, err := g.fields()
+	if err != nil {
+		return err
+	}
+	g.printf("\n// AppendPHP appends php serialized x to dst\n")
+	g.printf("func (x %s) AppendPHP(dst []byte) ([]byte, error) {\n", g.typ)
+	g.printf("var ptrs phpserialize.Pointers\nreturn x.appendPHP(dst, &ptrs)\n}\n")
+	g.printf("\nfunc (x %s) appendPHP(dst []byte, ptrs *phpserialize.Pointers) ([]byte, error) {\n", g.typ)
+	if g.needsErr(fields) {
+		g.printf("var err error\n")
+	}
+	g.printf("n := %d\n", len(fields))
+	for _, f := range fields {
+		if f.omitEmpty {
+			if cond := emptyCond("x."+f.goName, f.typ, true); cond != "" {
+				g.printf("if %s {\nn--\n}\n", cond)
+			}
+		}
+	}
+	if g.pkg.methods[g.typ]["PHPClassName"] {
+		g.printf("dst = phpserialize.AppendObjectHeader(dst, x.PHPClassName(), n)\n")
+	} else {
+		g.printf("dst = phpserialize.AppendArrayHeader(dst, n)\n")
+	}
+	for _, f := range fields {
+		cond := ""
+		if f.omitEmpty {
+			cond = emptyCond("x."+f.goName, f.typ, false)
+		}
+		if cond != "" {
+			g.printf("if %s {\n", cond)
+		}
+		g.key(f)
+		g.value("x."+f.goName, f.typ, true, 0)
+		if cond != "" {
+			g.printf("}\n")
+		}
+	}
+	g.printf("return append(dst, '}'), nil\n}\n")
+	g.printf("\n// MarshalPHP returns php serialized x\n")
+	g.printf("func (x %s) MarshalPHP() ([]byte, error) {\nreturn x.AppendPHP(nil)\n}\n", g.typ)
+	g.generateUnmarshal(fields)
+	return nil
+}
+
+// generateUnmarshal writes UnmarshalPHP method decoding fields the way
+// Unmarshal does, properties are matched by keys and names listed in
+// package variables
+func (g *generator) generateUnmarshal(fields []*field) {
+	g.printf("\nvar phpKeys%s = []string{", g.typ)
+	for _, f := range fields {
+		g.printf("%s, ", g.propertyKey(f))
+	}
+	g.printf("}\n\nvar phpNames%s = []string{", g.typ)
+	for _, f := range fields {
+		g.printf("%s, ", strconv.Quote(f.name))
+	}
+	g.printf("}\n")
+	g.printf("\n// UnmarshalPHP decodes php serialized data into x\n")
+	g.printf("func (x *%s) UnmarshalPHP(data []byte) error {\n", g.typ)
+	g.printf("p := phpserialize.NewParser(data)\nif err := x.unmarshalPHP(p); err != nil {\nreturn err\n}\nreturn p.Finish()\n}\n")
+	g.printf("\nfunc (x *%s) unmarshalPHP(p *phpserialize.Parser) error {\n", g.typ)
+	g.printf("n, err := p.ReadHeader()\nif err != nil {\nreturn err\n}\n")
+	g.printf("for i := 0; i < n; i++ {\nkey, err := p.ReadKey()\nif err != nil {\nreturn err\n}\n")
+	g.printf("switch phpserialize.MatchField(key, phpKeys%s, phpNames%s) {\n", g.typ, g.typ)
+	for i, f := range fields {
+		g.printf("case %d:\n", i)
+		g.read("x."+f.goName, f.typ, 0)
+	}
+	g.printf("default:\nif err = p.Skip(); err != nil {\nreturn err\n}\n}\n}\n")
+	g.printf("return p.ReadEnd()\n}\n")
+}
+
+// propertyKey returns expression of serialized name of property
+// Unmarshal matches first
+func (g *generator) propertyKey(f *field) string {
+	switch {
+	case f.protected:
+		return strconv.Quote("\x00*\x00" + f.name)
+	case f.private && g.pkg.methods[g.typ]["PHPClassName"]:
+		return fmt.Sprintf("\"\\x00\"+new(%s).PHPClassName()+%s", g.typ, strconv.Quote("\x00"+f.name))
+	case f.private:
+		// structs without class name are only written as stdClass
+		return strconv.Quote("\x00stdClass\x00" + f.name)
+	}
+	return strconv.Quote(f.name)
+}
+
+// read writes code decoding the next value into expr, N; sets pointers
+// and slices to nil and leaves other values alone like Unmarshal does
+func (g *generator) read(expr string, t *fieldType, depth int) {
+	v := fmt.Sprintf("v%d", depth)
+	switch t.kind {
+	case kindPtr, kindSlice, kindBytes:
+		g.printf("if p.Nil() {\n%s = nil\n} else {\n", expr)
+	default:
+		g.printf("if !p.Nil() {\n")
+	}
+	switch t.kind {
+	case kindBool:
+		g.printf("%s, err := p.ReadBool()\nif err != nil {\nreturn err\n}\n%s = %s\n", v, expr, v)
+	case kindInt:
+		g.printf("%s, err := p.ReadInt(%d)\nif err != nil {\nreturn err\n}\n", v, bitSizes[t.name])
+		g.printf("%s = %s\n", expr, convert(v, &fieldType{name: "int64"}, t.name))
+	case kindUint:
+		g.printf("%s, err := p.ReadUint(%d)\nif err != nil {\nreturn err\n}\n", v, bitSizes[t.name])
+		g.printf("%s = %s\n", expr, convert(v, &fieldType{name: "uint64"}, t.name))
+	case kindFloat32:
+		g.printf("%s, err := p.ReadFloat(32)\nif err != nil {\nreturn err\n}\n%s = float32(%s)\n", v, expr, v)
+	case kindFloat64:
+		g.printf("%s, err := p.ReadFloat(64)\nif err != nil {\nreturn err\n}\n%s = %s\n", v, expr, v)
+	case kindString:
+		g.printf("%s, err := p.ReadString()\nif err != nil {\nreturn err\n}\n%s = %s\n", v, expr, v)
+	case kindBytes:
+		g.printf("%s, err := p.ReadBytes()\nif err != nil {\nreturn err\n}\n%s = %s\n", v, expr, v)
+	case kindSlice:
+		if t.elem.kind == kindUint && bitSizes[t.elem.name] == 8 {
+			// byte slices written as arrays are read from strings too
+			g.printf("%s, err := p.ReadBytes()\nif err != nil {\nreturn err\n}\n%s = %s\n", v, expr, v)
+			break
+		}
+		n, i := fmt.Sprintf("n%d", depth), fmt.Sprintf("i%d", depth)
+		g.printf("%s, err := p.ReadHeader()\nif err != nil {\nreturn err\n}\n", n)
+		g.printf("%s = make(%s, %s)\n", expr, typeExpr(t), n)
+		g.printf("for j := 0; j < %s; j++ {\n", n)
+		g.printf("%s, err := p.ReadIndex(%s)\nif err != nil {\nreturn err\n}\n", i, n)
+		g.read(expr+"["+i+"]", t.elem, depth+1)
+		g.printf("}\nif err := p.ReadEnd(); err != nil {\nreturn err\n}\n")
+	case kindStruct:
+		g.printf("if err := %s; err != nil {\nreturn err\n}\n", g.readCall("&"+expr, t))
+	case kindPtr:
+		if g.pkg.generated[t.name] {
+			g.printf("if %s == nil {\n%s = new(%s)\n}\n", expr, expr, t.name)
+		}
+		g.printf("if err := %s; err != nil {\nreturn err\n}\n", g.readCall(expr, t))
+	}
+	g.printf("}\n")
+}
+
+// readCall returns call decoding struct ptr points to, generated
+// methods share the parser
+func (g *generator) readCall(ptr string, t *fieldType) string {
+	if g.pkg.generated[t.name] {
+		return strings.TrimPrefix(ptr, "&") + ".unmarshalPHP(p)"
+	}
+	if t.kind == kindPtr {
+		ptr = "&" + ptr
+	}
+	return "p.Decode(" + ptr + ")"
+}
+
+// bitSizes are sizes integer types are read with, 0 stands for int and uint
+var bitSizes = map[string]int{
+	"int8":   8,
+	"int16":  16,
+	"int32":  32,
+	"rune":   32,
+	"int64":  64,
+	"uint8":  8,
+	"byte":   8,
+	"uint16": 16,
+	"uint32": 32,
+	"uint64": 64,
+}
+
+// typeExpr returns go type of t
+func typeExpr(t *fieldType) string {
+	switch t.kind {
+	case kindBytes:
+		return "[]byte"
+	case kindPtr:
+		return "*" + t.name
+	case kindSlice:
+		return "[]" + typeExpr(t.elem)
+	}
+	return t.name
+}
+
+// key writes property name the way php serializes visibility
+func (g *generator) key(f *field) {
+	switch {
+	case f.protected:
+		g.printf("dst = append(dst, %s...)\n", strconv.Quote(encodeString("\x00*\x00"+f.name)))
+	case f.private && g.pkg.methods[g.typ]["PHPClassName"]:
+		g.printf("dst = phpserialize.AppendString(dst, \"\\x00\"+new(%s).PHPClassName()+%s)\n", g.typ, strconv.Quote("\x00"+f.name))
+	// private properties of structs written as arrays are not mangled
+	case !g.pkg.methods[g.typ]["PHPClassName"] && isIntegerKey(f.name):
+		// keys of arrays holding integers are integers in php
+		g.printf("dst = append(dst, %s...)\n", strconv.Quote("i:"+f.name+";"))
+	default:
+		g.printf("dst = append(dst, %s...)\n", strconv.Quote(encodeString(f.name)))
+	}
+}
+
+// value writes code appending expr, nil slices of struct fields are
+// written as empty arrays and nested ones as N; like Marshal does
+func (g *generator) value(expr string, t *fieldType, isField bool, depth int) {
+	switch t.kind {
+	case kindBool:
+		g.printf("dst = phpserialize.AppendBool(dst, %s)\n", expr)
+	case kindInt:
+		g.printf("dst = phpserialize.AppendInt(dst, %s)\n", convert(expr, t, "int64"))
+	case kindUint:
+		g.printf("dst = phpserialize.AppendUint(dst, %s)\n", convert(expr, t, "uint64"))
+	case kindFloat32:
+		g.printf("dst = phpserialize.AppendFloat(dst, float64(%s), 32)\n", expr)
+	case kindFloat64:
+		g.printf("dst = phpserialize.AppendFloat(dst, %s, 64)\n", expr)
+	case kindString:
+		g.printf("dst = phpserialize.AppendString(dst, %s)\n", expr)
+	case kindBytes:
+		g.printf("if %s == nil {\ndst = phpserialize.AppendNil(dst)\n} else {\n", expr)
+		g.printf("dst = phpserialize.AppendBytes(dst, %s)\n}\n", expr)
+	case kindStruct:
+		g.printf("if dst, err = %s; err != nil {\nreturn nil, err\n}\n", g.appendCall(expr, t))
+	case kindPtr:
+		g.printf("if %s == nil {\ndst = phpserialize.AppendNil(dst)\n} else ", expr)
+		g.printf("if err = ptrs.Add(%s); err != nil {\nreturn nil, err\n} else ", expr)
+		g.printf("if dst, err = %s; err != nil {\nreturn nil, err\n}\n", g.appendCall(expr, t))
+	case kindSlice:
+		if !isField {
+			g.printf("if %s == nil {\ndst = phpserialize.AppendNil(dst)\n} else {\n", expr)
+		}
+		i, v := fmt.Sprintf("i%d", depth), fmt.Sprintf("v%d", depth)
+		g.printf("dst = phpserialize.AppendArrayHeader(dst, len(%s))\n", expr)
+		g.printf("for %s, %s := range %s {\n", i, v, expr)
+		g.printf("dst = phpserialize.AppendInt(dst, int64(%s))\n", i)
+		g.value(v, t.elem, false, depth+1)
+		g.printf("}\ndst = append(dst, '}')\n")
+		if !isField {
+			g.printf("}\n")
+		}
+	}
+}
+
+// appendCall returns call appending struct expr, generated methods
+// share set of pointers met so far
+func (g *generator) appendCall(expr string, t *fieldType) string {
+	if g.pkg.generated[t.name] {
+		return expr + ".appendPHP(dst, ptrs)"
+	}
+	return expr + ".AppendPHP(dst)"
+}
+
+// convert returns expr converted to typ unless it already is one
+func convert(expr string, t *fieldType, typ string) string {
+	if t.name == typ {
+		return expr
+	}
+	return typ + "(" + expr + ")"
+}
+
+func (g *generator) needsErr(fields []*field) bool {
+	for _, f := range fields {
+		for t := f.typ; t != nil; t = t.elem {
+			if t.kind == kindStruct || t.kind == kindPtr {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// emptyCond returns condition omitempty fields are skipped on,
+// or the opposite one when empty is false
+func emptyCond(expr string, t *fieldType, empty bool) string {
+	op, not := "==", "!"
+	if !empty {
+		op, not = "!=", ""
+	}
+	switch t.kind {
+	case kindBool:
+		return not + expr
+	case kindInt, kindUint, kindFloat32, kindFloat64:
+		return expr + " " + op + " 0"
+	case kindString:
+		return expr + " " + op + ` ""`
+	case kindBytes, kindSlice:
+		return "len(" + expr + ") " + op + " 0"
+	case kindPtr:
+		return expr + " " + op + " nil"
+	}
+	return ""
+}
+
+// fields lists fields in order Marshal writes them, of fields with
+// the same name tagged one wins, otherwise the first one
+func (g *generator) fields() ([]*field, error) {
+	var fields []*field
+	at := map[string]int{}
+	orphans := map[int]bool{}
+	for _, af := range g.pkg.structs[g.typ].Fields.List {
+		if len(af.Names) == 0 {
+			return nil, fmt.Errorf("%s: embedded fields are not supported", g.typ)
+		}
+		var tag reflect.StructTag
+		if af.Tag != nil {
+			s, err := strconv.Unquote(af.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(s)
+		}
+		name, opts := fieldTag(tag)
+		if name == "-" {
+			continue
+		}
+		for _, id := range af.Names {
+			if !id.IsExported() {
+				continue
+			}
+			f := &field{goName: id.Name, name: name, tagged: name != ""}
+			if f.name == "" {
+				f.name = id.Name
+			}
+			array := false
+			for _, opt := range opts {
+				switch opt {
+				case "omitempty":
+					f.omitEmpty = true
+				case "protected":
+					f.protected = true
+				case "private":
+					f.private = true
+				case "array":
+					array = true
+				case "string":
+					return nil, fmt.Errorf("%s.%s: string option is not supported", g.typ, id.Name)
+				}
+			}
+			t, err := g.fieldType(af.Type, array)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %v", g.typ, id.Name, err)
+			}
+			f.typ = t
+			if i, ok := at[f.name]; ok {
+				if fields[i].tagged || !f.tagged {
+					continue
+				}
+				orphans[i] = true
+			}
+			at[f.name] = len(fields)
+			fields = append(fields, f)
+		}
+	}
+	result := fields[:0]
+	for i, f := range fields {
+		if !orphans[i] {
+			result = append(result, f)
+		}
+	}
+	return result, nil
+}
+
+// fieldTag reads the first of php and json tags present on field
+func fieldTag(tag reflect.StructTag) (string, []string) {
+	for _, key := range []string{"php", "json"} {
+		if v := tag.Get(key); v != "" {
+			parts := strings.Split(v, ",")
+			return parts[0], parts[1:]
+		}
+	}
+	return "", nil
+}
+
+func (g *generator) fieldType(expr ast.Expr, array bool) (*fieldType, error) {
+	switch expr := expr.(type) {
+	case *ast.Ident:
+		if k, ok := basicKinds[expr.Name]; ok {
+			return &fieldType{kind: k, name: expr.Name}, nil
+		}
+		if g.pkg.methods[expr.Name]["AppendPHP"] {
+			return &fieldType{kind: kindStruct, name: expr.Name}, nil
+		}
+	case *ast.StarExpr:
+		if id, ok := expr.X.(*ast.Ident); ok && g.pkg.methods[id.Name]["AppendPHP"] {
+			return &fieldType{kind: kindPtr, name: id.Name}, nil
+		}
+	case *ast.ArrayType:
+		if expr.Len != nil {
+			break
+		}
+		if id, ok := expr.Elt.(*ast.Ident); ok && !array && (id.Name == "byte" || id.Name == "uint8") {
+			return &fieldType{kind: kindBytes}, nil
+		}
+		elem, err := g.fieldType(expr.Elt, false)
+		if err != nil {
+			return nil, err
+		}
+		return &fieldType{kind: kindSlice, elem: elem}, nil
+	}
+	var buf bytes.Buffer
+	format.Node(&buf, token.NewFileSet(), expr)
+	return nil, fmt.Errorf("type %s is not supported", buf.String())
+}
+
+// isIntegerKey reports whether php converts array key s to integer
+func isIntegerKey(s string) bool {
+	i, err := strconv.ParseInt(s, 10, 64)
+	return err == nil && strconv.FormatInt(i, 10) == s
+}
+
+// encodeString returns s serialized as php string
+func encodeString(s string) string {
+	return "s:" + strconv.Itoa(len(s)) + `:"` + s + `";`
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateUpToDate(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "gentest")
+	src, err := generate(filepath.Join(dir, "types.go"), []string{"User", "Address", "Tags", "Point"})
+	if !assert.NoError(t, err) {
+		return
+	}
+	want, err := ioutil.ReadFile(filepath.Join(dir, "types_phpgen.go"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(src))
+}
+
+func TestGenerateTypes(t *testing.T) {
+	dir := filepath.Join("..", "..", "internal", "gentest")
+	_, err := generate(filepath.Join(dir, "types.go"), []string{"Missing"})
+	assert.EqualError(t, err, "Missing is not a struct type")
+	src, err := generate(filepath.Join(dir, "types.go"), nil)
+	assert.NoError(t, err)
+	assert.Contains(t, string(src), "func (x Tags) AppendPHP")
+	assert.Contains(t, string(src), "func (x *Tags) UnmarshalPHP")
+}
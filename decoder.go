@@ -0,0 +1,426 @@
+package phpserialize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var errUnexpectedEnd = errors.New("unexpected end of php serialized data")
+
+// Decoder implementing php unserialize functionality
+type Decoder struct {
+	r     io.Reader
+	buf   []byte
+	scanp int
+	err   error
+	// scanned is number of stream bytes dropped from buf
+	scanned int64
+	// scan is progress of validating value at scanp
+	scan scanner
+
+	data []byte
+	off  int
+	// base is offset of data in the input stream
+	base int64
+	// fields is path of struct fields being decoded
+	fields []string
+
+	slots    []decSlot
+	replayed map[replayKey]reflect.Value
+	values   int
+	scanning bool
+	replay   int
+	// detached decoder reads part of serialized data, slots
+	// references in it point to are unknown
+	detached bool
+
+	depth      int
+	maxDepth   int
+	timeLayout string
+}
+
+// NewDecoder creates new decoder reading from r
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{r: r}
+}
+
+// SetTimeLayout sets layout strings are parsed with when decoding into
+// time.Time, by default RFC 3339 and php Y-m-d H:i:s layouts are accepted
+func (d *Decoder) SetTimeLayout(layout string) {
+	d.timeLayout = layout
+}
+
+// SetMaxDepth limits nesting of arrays and objects, 0 means default
+// limit of 10000 and negative depth means no limit
+func (d *Decoder) SetMaxDepth(depth int) {
+	d.maxDepth = depth
+}
+
+// Decode reads next php serialized value from its input and stores
+// it in the value pointed to by v
+func (d *Decoder) Decode(v interface{}) error {
+	n, err := d.readValue()
+	if err != nil {
+		return err
+	}
+	d.base = d.scanned + int64(d.scanp)
+	err = d.unmarshal(d.buf[d.scanp:d.scanp+n], v)
+	d.scanp += n
+	return err
+}
+
+// readValue buffers input until it holds one complete serialized value
+// and returns its length
+func (d *Decoder) readValue() (int, error) {
+	for {
+		for d.scanp < len(d.buf) && isSpace(d.buf[d.scanp]) {
+			d.scanp++
+		}
+		if d.scanp < len(d.buf) {
+			d.scan.maxDepth = d.maxDepth
+			n, err := d.scan.scan(d.buf[d.scanp:])
+			if err == nil {
+				d.scan.reset()
+				return n, nil
+			}
+			if err != errUnexpectedEnd {
+				d.scan.reset()
+				if se, ok := err.(*SyntaxError); ok {
+					se.Offset += d.scanned + int64(d.scanp)
+				}
+				return 0, err
+			}
+		}
+		if d.err != nil {
+			if d.err == io.EOF && d.scanp < len(d.buf) {
+				return 0, io.ErrUnexpectedEOF
+			}
+			return 0, d.err
+		}
+		d.refill()
+	}
+}
+
+func (d *Decoder) refill() {
+	if d.scanp > 0 {
+		d.scanned += int64(d.scanp)
+		n := copy(d.buf, d.buf[d.scanp:])
+		d.buf = d.buf[:n]
+		d.scanp = 0
+	}
+	const minRead = 512
+	if cap(d.buf)-len(d.buf) < minRead {
+		buf := make([]byte, len(d.buf), 2*cap(d.buf)+minRead)
+		copy(buf, d.buf)
+		d.buf = buf
+	}
+	n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+	d.buf = d.buf[:len(d.buf)+n]
+	d.err = err
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
+
+// Unmarshal parses php serialized data and stores the result
+// in the value pointed to by v
+func Unmarshal(data []byte, v interface{}) error {
+	n, err := scanValue(data, 0)
+	if err == errUnexpectedEnd {
+		return &SyntaxError{msg: "phpserialize: " + err.Error(), Offset: int64(len(data))}
+	}
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return &SyntaxError{msg: fmt.Sprintf("phpserialize: invalid character %q after top-level value", data[n]), Offset: int64(n)}
+	}
+	var d Decoder
+	return d.unmarshal(data, v)
+}
+
+func (d *Decoder) unmarshal(data []byte, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	d.data = data
+	d.off = 0
+	d.depth = 0
+	d.fields = d.fields[:0]
+	d.slots = d.slots[:0]
+	d.replayed = nil
+	d.values = 0
+	return d.decodeValue(rv)
+}
+
+func (d *Decoder) decodeValue(v reflect.Value) error {
+	if d.off >= len(d.data) {
+		return errUnexpectedEnd
+	}
+	if c := d.data[d.off]; c != 'r' && c != 'R' && c != 'N' {
+		if u := indirectUnmarshaler(v); u != nil {
+			return d.decodeUnmarshaler(v, u)
+		}
+	}
+	if d.data[d.off] != 'R' {
+		d.pushSlot(v)
+	}
+	if c := d.data[d.off]; c != 'r' && c != 'R' && c != 'N' && isTimeType(v.Type()) {
+		return d.decodeTime(v)
+	}
+	if c := d.data[d.off]; c != 'r' && c != 'R' && c != 'N' && isDurationType(v.Type()) {
+		return d.decodeDuration(v)
+	}
+	if c := d.data[d.off]; (c == 'i' || c == 'd') && isNumberType(derefType(v.Type())) {
+		return d.decodeNumber(v)
+	}
+	switch d.data[d.off] {
+	case 'r', 'R':
+		return d.decodeRef(v)
+	case 'N':
+		return d.decodeNil(v)
+	case 'b':
+		return d.decodeBool(v)
+	case 'i':
+		return d.decodeInteger(v)
+	case 'd':
+		return d.decodeFloating(v)
+	case 's':
+		return d.decodeString(v)
+	case 'a':
+		return d.decodeArray(v)
+	case 'O':
+		return d.decodeObject(v)
+	case 'C':
+		return d.decodeCustom(v)
+	case 'E':
+		return d.decodeEnum(v)
+	}
+	return d.syntaxError("value")
+}
+
+// tokenName describes serialized value starting with c in errors
+func tokenName(c byte) string {
+	switch c {
+	case 'b':
+		return "bool"
+	case 'i':
+		return "integer"
+	case 'd':
+		return "float"
+	case 'a':
+		return "array"
+	case 'O', 'C':
+		return "object"
+	case 'E':
+		return "enum case"
+	}
+	return "value"
+}
+
+// decodeKey reads array key which is either int64 or string
+func (d *Decoder) decodeKey() (interface{}, error) {
+	if d.off >= len(d.data) {
+		return nil, errUnexpectedEnd
+	}
+	switch d.data[d.off] {
+	case 'i':
+		return d.readInteger()
+	case 's':
+		b, err := d.readString()
+		return string(b), err
+	}
+	return nil, d.syntaxError("array key")
+}
+
+// enter is called when decoder descends into array or object
+func (d *Decoder) enter() error {
+	d.depth++
+	if max := maxDepthOf(d.maxDepth); max > 0 && d.depth > max {
+		return d.errorf("exceeded max depth of %d", max)
+	}
+	return nil
+}
+
+func (d *Decoder) leave() {
+	d.depth--
+}
+
+func (d *Decoder) decodeArray(v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	if err := d.expectPrefix('a'); err != nil {
+		return err
+	}
+	n, err := d.readLen(':')
+	if err != nil {
+		return err
+	}
+	if err := d.expect('{'); err != nil {
+		return err
+	}
+	if err := d.decodeProps(v, n); err != nil {
+		return err
+	}
+	return d.expect('}')
+}
+
+// decodeProps decodes n key value pairs of array or object into v
+func (d *Decoder) decodeProps(v reflect.Value, n int) error {
+	v = indirect(v, false)
+	switch {
+	case isOrderedArray(v), isEmptyInterface(v):
+		arr, err := d.readOrderedArray(n)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(arr))
+		return nil
+	case isIterable(v):
+		return d.decodeIterable(v, n)
+	case isMap(v):
+		return d.decodeMap(v, n)
+	case isStruct(v):
+		return d.decodeStruct(v, n)
+	}
+	return d.typeError("array", v)
+}
+
+func (d *Decoder) skipProps(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := d.decodeKey(); err != nil {
+			return err
+		}
+		if err := d.skip(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// indirect walks down v allocating pointers as needed, until
+// it gets to a non-pointer
+func indirect(v reflect.Value, decodingNil bool) reflect.Value {
+	for {
+		if v.Kind() == reflect.Interface && !v.IsNil() {
+			e := v.Elem()
+			if e.Kind() == reflect.Ptr && !e.IsNil() && (!decodingNil || e.Elem().Kind() == reflect.Ptr) {
+				v = e
+				continue
+			}
+		}
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		if decodingNil && v.CanSet() {
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// derefType returns type pointer type t eventually points to
+func derefType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+func isEmptyInterface(v reflect.Value) bool {
+	return v.Kind() == reflect.Interface && v.NumMethod() == 0
+}
+
+func (d *Decoder) expect(c byte) error {
+	if d.off >= len(d.data) {
+		return errUnexpectedEnd
+	}
+	if d.data[d.off] != c {
+		return d.syntaxError(strconv.QuoteRune(rune(c)))
+	}
+	d.off++
+	return nil
+}
+
+// expectPrefix consumes type prefix of a value such as "i:"
+func (d *Decoder) expectPrefix(typ byte) error {
+	if err := d.expect(typ); err != nil {
+		return err
+	}
+	return d.expect(':')
+}
+
+// readUntil returns bytes up to the end character and consumes it
+func (d *Decoder) readUntil(end byte) ([]byte, error) {
+	i := bytes.IndexByte(d.data[d.off:], end)
+	if i < 0 {
+		return nil, errUnexpectedEnd
+	}
+	b := d.data[d.off : d.off+i]
+	d.off += i + 1
+	return b, nil
+}
+
+func (d *Decoder) readLen(end byte) (int, error) {
+	start := d.off
+	b, err := d.readUntil(end)
+	if err != nil {
+		return 0, err
+	}
+	l, err := strconv.ParseUint(string(b), 10, 31)
+	if err != nil {
+		d.off = start
+		return 0, d.syntaxError("length")
+	}
+	return int(l), nil
+}
+
+// readQuoted reads "..." of length l
+func (d *Decoder) readQuoted(l int) ([]byte, error) {
+	if err := d.expect('"'); err != nil {
+		return nil, err
+	}
+	if len(d.data)-d.off < l {
+		return nil, errUnexpectedEnd
+	}
+	b := d.data[d.off : d.off+l]
+	d.off += l
+	if err := d.expect('"'); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+func (d *Decoder) syntaxError(expected string) error {
+	if d.off >= len(d.data) {
+		return errUnexpectedEnd
+	}
+	return d.errorf("invalid character %q looking for %s", d.data[d.off], expected)
+}
+
+// errorf returns SyntaxError at current offset
+func (d *Decoder) errorf(format string, args ...interface{}) error {
+	return &SyntaxError{msg: "phpserialize: " + fmt.Sprintf(format, args...), Offset: d.base + int64(d.off)}
+}
+
+func (d *Decoder) typeError(what string, v reflect.Value) error {
+	return &UnmarshalTypeError{
+		Value:  what,
+		Type:   v.Type(),
+		Offset: d.base + int64(d.off),
+		Field:  strings.Join(d.fields, "."),
+	}
+}
@@ -0,0 +1,597 @@
+package phpserialize
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPhpUnserialize(t *testing.T) {
+	assert := assert.New(t)
+	type Embedded struct {
+		CField string `php:"c_field"`
+	}
+	type PhpTagged struct {
+		AField int     `php:"a_field"`
+		BField float64 `php:"b_field,omitempty"`
+		SField int     `php:"s_field,string"`
+		*Embedded
+	}
+
+	var i int
+	assert.NoError(Unmarshal([]byte("i:5;"), &i))
+	assert.Equal(5, i)
+
+	var f float64
+	assert.NoError(Unmarshal([]byte("d:5.6;"), &f))
+	assert.Equal(5.6, f)
+
+	var s string
+	assert.NoError(Unmarshal([]byte("s:23:\"Bj\xc3\xb6rk Gu\xc3\xb0mundsd\xc3\xb3ttir\";"), &s))
+	assert.Equal("Björk Guðmundsdóttir", s)
+
+	var b bool
+	assert.NoError(Unmarshal([]byte("b:1;"), &b))
+	assert.True(b)
+
+	var ints []int
+	assert.NoError(Unmarshal([]byte("a:3:{i:0;i:7;i:1;i:8;i:2;i:9;}"), &ints))
+	assert.Equal([]int{7, 8, 9}, ints)
+
+	var m map[string]int
+	assert.NoError(Unmarshal([]byte(`a:2:{s:1:"a";i:1;i:5;i:2;}`), &m))
+	assert.Equal(map[string]int{"a": 1, "5": 2}, m)
+
+	var p *int
+	assert.NoError(Unmarshal([]byte("i:3;"), &p))
+	assert.Equal(3, *p)
+	assert.NoError(Unmarshal([]byte("N;"), &p))
+	assert.Nil(p)
+
+	var tagged PhpTagged
+	assert.NoError(Unmarshal([]byte(`a:4:{s:7:"a_field";i:1;s:7:"b_field";d:0.5;s:7:"s_field";s:2:"42";s:7:"c_field";s:1:"c";}`), &tagged))
+	assert.Equal(PhpTagged{AField: 1, BField: 0.5, SField: 42, Embedded: &Embedded{CField: "c"}}, tagged)
+
+	var obj PhpTagged
+	assert.NoError(Unmarshal([]byte(`O:3:"Foo":2:{s:7:"a_field";i:2;s:7:"unknown";a:1:{i:0;N;}}`), &obj))
+	assert.Equal(PhpTagged{AField: 2}, obj)
+
+	var iface interface{}
+	assert.NoError(Unmarshal([]byte(`a:2:{i:0;s:1:"a";s:1:"b";a:1:{i:0;b:0;}}`), &iface))
+	assert.Equal(OrderedArray{
+		{Key: int64(0), Value: "a"},
+		{Key: "b", Value: OrderedArray{{Key: int64(0), Value: false}}},
+	}, iface)
+
+	var po PhpObject
+	assert.NoError(Unmarshal([]byte(`O:3:"Foo":1:{s:1:"a";i:1;}`), &po))
+	assert.Equal(PhpObject{Name: "Foo", PhpVars: OrderedArray{{Key: "a", Value: int64(1)}}}, po)
+
+	assert.Error(Unmarshal([]byte("i:5;"), i))
+	assert.Error(Unmarshal([]byte("i:5"), &i))
+	assert.Error(Unmarshal([]byte("i:5;i:6;"), &i))
+	assert.Error(Unmarshal([]byte(`s:5:"abc";`), &s))
+	assert.Error(Unmarshal([]byte("s:1:\"a\";"), &i))
+	assert.Error(Unmarshal([]byte("i:300;"), new(int8)))
+
+	var u struct{ U uint64 }
+	assert.NoError(Unmarshal([]byte(`a:1:{s:1:"U";i:18446744073709551615;}`), &u))
+	assert.Equal(uint64(math.MaxUint64), u.U)
+	assert.Error(Unmarshal([]byte("i:18446744073709551616;"), &u.U))
+	assert.Error(Unmarshal([]byte("i:18446744073709551615;"), new(uint32)))
+
+	var strs []string
+	assert.NoError(Unmarshal([]byte(`a:2:{i:1;s:1:"b";i:0;s:1:"a";}`), &strs))
+	assert.Equal([]string{"a", "b"}, strs)
+	var arr [1]string
+	assert.NoError(Unmarshal([]byte(`a:2:{i:1;s:1:"b";i:0;s:1:"a";}`), &arr))
+	assert.Equal([1]string{"a"}, arr)
+	err := Unmarshal([]byte(`a:1:{s:1:"k";s:1:"v";}`), &strs)
+	assert.EqualError(err, `phpserialize: cannot unmarshal array key "k" into Go value of type []string`)
+	assert.IsType(&UnmarshalTypeError{}, err)
+	assert.IsType(&UnmarshalTypeError{}, Unmarshal([]byte(`a:1:{i:1;s:1:"v";}`), &strs))
+
+	err = Unmarshal([]byte(`a:1:{s:7:"s_field";i:5;}`), &tagged)
+	assert.EqualError(err, "phpserialize: cannot unmarshal integer into Go struct field s_field of type int")
+	assert.IsType(&UnmarshalTypeError{}, err)
+	var refs struct {
+		A int `php:"a,string"`
+		B int `php:"b,string"`
+	}
+	assert.NoError(Unmarshal([]byte(`a:2:{s:1:"a";s:1:"7";s:1:"b";R:2;}`), &refs))
+	assert.Equal(7, refs.B)
+}
+
+func TestPhpDecoder(t *testing.T) {
+	assert := assert.New(t)
+	dec := NewDecoder(bytes.NewBufferString(`i:1;s:3:"abc";` + "\n" + `a:1:{i:0;d:1.5;}`))
+	var i int
+	var s string
+	var fs []float64
+	assert.NoError(dec.Decode(&i))
+	assert.NoError(dec.Decode(&s))
+	assert.NoError(dec.Decode(&fs))
+	assert.Equal(1, i)
+	assert.Equal("abc", s)
+	assert.Equal([]float64{1.5}, fs)
+	assert.Equal(io.EOF, dec.Decode(&i))
+
+	dec = NewDecoder(bytes.NewBufferString(`s:3:"ab`))
+	assert.Equal(io.ErrUnexpectedEOF, dec.Decode(&s))
+
+	// scanning resumes where it stopped, reading large value byte
+	// by byte would take ages if it was scanned from start each time
+	rows := make([]map[string]string, 20000)
+	for i := range rows {
+		rows[i] = map[string]string{"k": strconv.Itoa(i)}
+	}
+	data, err := Marshal(rows)
+	assert.NoError(err)
+	dec = NewDecoder(iotest.OneByteReader(bytes.NewReader(append(data, `i:7;`...))))
+	var decoded []map[string]string
+	assert.NoError(dec.Decode(&decoded))
+	assert.Equal(rows, decoded)
+	assert.NoError(dec.Decode(&i))
+	assert.Equal(7, i)
+
+	dec = NewDecoder(iotest.OneByteReader(strings.NewReader(`i:1;a:1:{i:0;a:1:{s:1:"k";x:0;}}`)))
+	assert.NoError(dec.Decode(&i))
+	err = dec.Decode(&decoded)
+	if assert.IsType(&SyntaxError{}, err) {
+		assert.Equal(int64(26), err.(*SyntaxError).Offset)
+	}
+}
+
+func TestPhpRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	type PhpTagged struct {
+		AField int               `php:"a_field"`
+		BField []string          `php:"b_field"`
+		CField map[string]string `php:"c_field,omitempty"`
+	}
+	in := PhpTagged{AField: 1, BField: []string{"x", "y"}, CField: map[string]string{"k": "v"}}
+	b, err := Marshal(in)
+	assert.NoError(err)
+	var out PhpTagged
+	assert.NoError(Unmarshal(b, &out))
+	assert.Equal(in, out)
+}
+
+func TestPhpValueRoundTrip(t *testing.T) {
+	assert := assert.New(t)
+	data := []string{
+		`a:4:{i:3;s:1:"a";s:1:"x";O:3:"Foo":2:{s:1:"p";b:1;s:1:"q";N;}i:0;d:0.5;s:1:"y";a:0:{}}`,
+		`O:8:"stdClass":1:{s:4:"list";a:2:{i:0;i:1;i:1;i:-2;}}`,
+		`a:2:{i:0;i:5;i:1;R:2;}`,
+		`a:3:{i:0;a:1:{i:0;s:1:"a";}i:1;R:2;i:2;R:3;}`,
+		`a:3:{i:0;O:3:"Foo":0:{}i:1;r:2;i:2;R:2;}`,
+	}
+	for _, tt := range data {
+		var v interface{}
+		assert.NoError(Unmarshal([]byte(tt), &v))
+		b, err := Marshal(v)
+		assert.NoError(err)
+		assert.Equal(tt, string(b))
+	}
+
+	var v interface{}
+	assert.NoError(Unmarshal([]byte(data[0]), &v))
+	arr := v.(OrderedArray)
+	assert.Equal(&Object{
+		Class: "Foo",
+		Properties: OrderedArray{
+			{Key: "p", Value: true},
+			{Key: "q", Value: nil},
+		},
+	}, arr[1].Value)
+
+	var obj Object
+	assert.NoError(Unmarshal([]byte(data[1]), &obj))
+	assert.Equal("stdClass", obj.Class)
+	assert.Len(obj.Properties, 1)
+
+	assert.NoError(Unmarshal([]byte(data[2]), &v))
+	assert.Equal(OrderedArray{
+		{Key: int64(0), Value: int64(5)},
+		{Key: int64(1), Value: Reference{Slot: 2, Value: int64(5)}},
+	}, v)
+	_, err := Marshal(OrderedArray{{Key: 0, Value: Reference{Slot: 2}}})
+	assert.Error(err)
+}
+
+func TestPhpUnserializeReferences(t *testing.T) {
+	assert := assert.New(t)
+	type Foo struct {
+		A int `php:"a"`
+	}
+	type Node struct {
+		Name string
+		Next *Node
+	}
+
+	var foos []*Foo
+	assert.NoError(Unmarshal([]byte(`a:2:{i:0;O:3:"Foo":1:{s:1:"a";i:1;}i:1;r:2;}`), &foos))
+	assert.Len(foos, 2)
+	assert.True(foos[0] == foos[1])
+	assert.Equal(1, foos[1].A)
+
+	var v interface{}
+	assert.NoError(Unmarshal([]byte(`a:2:{i:0;O:3:"Foo":1:{s:1:"a";i:1;}i:1;r:2;}`), &v))
+	arr := v.(OrderedArray)
+	assert.True(arr[0].Value.(*Object) == arr[1].Value.(*Object))
+
+	var n Node
+	assert.NoError(Unmarshal([]byte(`a:2:{s:4:"Name";s:1:"a";s:4:"Next";R:1;}`), &n))
+	assert.True(n.Next == &n)
+
+	var ints struct{ A, B *int }
+	assert.NoError(Unmarshal([]byte(`a:2:{s:1:"A";i:5;s:1:"B";R:2;}`), &ints))
+	assert.True(ints.A == ints.B)
+	assert.Equal(5, *ints.B)
+
+	var skipped struct{ B *Foo }
+	assert.NoError(Unmarshal([]byte(`a:2:{s:1:"A";O:3:"Foo":1:{s:1:"a";i:7;}s:1:"B";r:2;}`), &skipped))
+	assert.Equal(&Foo{A: 7}, skipped.B)
+
+	assert.Error(Unmarshal([]byte(`a:1:{i:0;r:5;}`), &v))
+	assert.Error(Unmarshal([]byte(`a:1:{i:0;r:0;}`), &v))
+
+	// each skipped array references the previous one twice, decoding
+	// them again for every reference would take exponential time
+	const levels = 40
+	var chain strings.Builder
+	chain.WriteString(`a:2:{s:4:"Skip";a:` + strconv.Itoa(levels) + `:{i:0;a:0:{}`)
+	for i := 1; i < levels; i++ {
+		prev := strconv.Itoa(3 + i - 1)
+		chain.WriteString(`i:` + strconv.Itoa(i) + `;a:2:{i:0;R:` + prev + `;i:1;R:` + prev + `;}`)
+	}
+	chain.WriteString(`}s:4:"Keep";R:` + strconv.Itoa(3+levels-1) + `;}`)
+	var keep struct{ Keep interface{} }
+	assert.NoError(Unmarshal([]byte(chain.String()), &keep))
+	depth := 0
+	for ref, ok := keep.Keep.(Reference); ok; ref, ok = ref.Value.(OrderedArray)[0].Value.(Reference) {
+		depth++
+		if len(ref.Value.(OrderedArray)) == 0 {
+			break
+		}
+	}
+	assert.Equal(levels, depth)
+}
+
+func TestPhpUnserializeRegisteredClass(t *testing.T) {
+	assert := assert.New(t)
+	RegisterClass(`App\User`, &phpUser{})
+	var v interface{}
+	data := `O:8:"App\User":3:{s:4:"name";s:1:"a";s:6:"friend";r:1;s:7:"address";O:7:"Address":1:{s:4:"city";s:1:"b";}}`
+	assert.NoError(Unmarshal([]byte(data), &v))
+	u, ok := v.(*phpUser)
+	assert.True(ok)
+	assert.Equal("a", u.Name)
+	assert.Equal("b", u.Address.City)
+	assert.True(u.Friend == u)
+
+	b, err := Marshal(u)
+	assert.NoError(err)
+	assert.Equal(data, string(b))
+}
+
+func TestPhpUnserializeVisibility(t *testing.T) {
+	assert := assert.New(t)
+	expected := phpEntity{phpBase: phpBase{ID: 7}, Name: "n", Secret: "s", Public: 1}
+	var e phpEntity
+	assert.NoError(Unmarshal([]byte("O:6:\"Entity\":4:{s:7:\"\x00*\x00name\";s:1:\"n\";s:14:\"\x00Entity\x00secret\";s:1:\"s\";s:6:\"public\";i:1;s:8:\"\x00Base\x00id\";i:7;}"), &e))
+	assert.Equal(expected, e)
+
+	e = phpEntity{}
+	assert.NoError(Unmarshal([]byte(`a:4:{s:4:"name";s:1:"n";s:6:"secret";s:1:"s";s:6:"public";i:1;s:2:"id";i:7;}`), &e))
+	assert.Equal(expected, e)
+}
+
+func TestPhpUnserializeSerializable(t *testing.T) {
+	assert := assert.New(t)
+	data := `a:3:{i:0;C:5:"Money":7:{100 EUR}i:1;r:2;i:2;C:3:"Foo":1:{x}}`
+
+	var v interface{}
+	assert.NoError(Unmarshal([]byte(data), &v))
+	arr := v.(OrderedArray)
+	assert.Equal(&CustomObject{Class: "Money", Data: []byte("100 EUR")}, arr[0].Value)
+	b, err := Marshal(v)
+	assert.NoError(err)
+	assert.Equal(data, string(b))
+
+	RegisterClass("Money", phpMoney{})
+	assert.NoError(Unmarshal([]byte(data), &v))
+	arr = v.(OrderedArray)
+	assert.Equal(&phpMoney{Amount: 100, Currency: "EUR"}, arr[0].Value)
+	assert.True(arr[0].Value == arr[1].Value)
+
+	var ms []phpMoney
+	assert.NoError(Unmarshal([]byte(`a:1:{i:0;C:5:"Money":5:{1 USD}}`), &ms))
+	assert.Equal([]phpMoney{{Amount: 1, Currency: "USD"}}, ms)
+	assert.Error(Unmarshal([]byte(`C:5:"Money":5:{1 USD}`), new(int)))
+}
+
+func TestPhpUnserializeEnum(t *testing.T) {
+	assert := assert.New(t)
+	var s struct {
+		Suit  phpSuit     `php:"suit"`
+		Other interface{} `php:"other"`
+	}
+	assert.NoError(Unmarshal([]byte(`a:2:{s:4:"suit";E:11:"Suit:Spades";s:5:"other";E:13:"Status:Active";}`), &s))
+	assert.Equal(phpSpades, s.Suit)
+	assert.Equal(Enum{Class: "Status", Case: "Active"}, s.Other)
+
+	var v interface{}
+	assert.NoError(Unmarshal([]byte(`E:11:"Suit:Hearts";`), &v))
+	assert.Equal(phpHearts, v)
+
+	assert.Error(Unmarshal([]byte(`E:10:"Suit:Clubs";`), &s.Suit))
+	assert.Error(Unmarshal([]byte(`E:13:"Status:Active";`), &s.Suit))
+	assert.Error(Unmarshal([]byte(`E:6:"Status";`), &v))
+}
+
+func TestPhpUnserializeFloating(t *testing.T) {
+	assert := assert.New(t)
+	var f float64
+	assert.NoError(Unmarshal([]byte("d:INF;"), &f))
+	assert.True(math.IsInf(f, 1))
+	assert.NoError(Unmarshal([]byte("d:-INF;"), &f))
+	assert.True(math.IsInf(f, -1))
+	assert.NoError(Unmarshal([]byte("d:NAN;"), &f))
+	assert.True(math.IsNaN(f))
+	assert.NoError(Unmarshal([]byte("d:1.0E+25;"), &f))
+	assert.Equal(1e25, f)
+}
+
+func TestPhpUnserializeErrors(t *testing.T) {
+	assert := assert.New(t)
+	type Address struct {
+		Zip int `php:"zip"`
+	}
+	type User struct {
+		Address Address `php:"address"`
+	}
+
+	var u User
+	err := Unmarshal([]byte(`a:1:{s:7:"address";a:1:{s:3:"zip";s:1:"x";}}`), &u)
+	var typeErr *UnmarshalTypeError
+	if assert.True(errors.As(err, &typeErr), "%v", err) {
+		assert.Equal("string", typeErr.Value)
+		assert.Equal(reflect.TypeOf(0), typeErr.Type)
+		assert.Equal("address.zip", typeErr.Field)
+		assert.Equal(int64(42), typeErr.Offset)
+	}
+
+	var syntaxErr *SyntaxError
+	var i int
+	if assert.True(errors.As(Unmarshal([]byte("i:5x;"), &i), &syntaxErr)) {
+		assert.Equal(int64(3), syntaxErr.Offset)
+	}
+	if assert.True(errors.As(Unmarshal([]byte("i:5;i:6;"), &i), &syntaxErr)) {
+		assert.Equal(int64(4), syntaxErr.Offset)
+	}
+	if assert.True(errors.As(Unmarshal([]byte("i:5"), &i), &syntaxErr)) {
+		assert.Equal(int64(3), syntaxErr.Offset)
+	}
+	dec := NewDecoder(bytes.NewBufferString(`i:1; i:x;`))
+	assert.NoError(dec.Decode(&i))
+	if assert.True(errors.As(dec.Decode(&i), &syntaxErr)) {
+		assert.Equal(int64(7), syntaxErr.Offset)
+	}
+
+	var invalidErr *InvalidUnmarshalError
+	assert.True(errors.As(Unmarshal([]byte("i:5;"), i), &invalidErr))
+	assert.True(errors.As(Unmarshal([]byte("i:5;"), nil), &invalidErr))
+}
+
+type phpCents int64
+
+func (c *phpCents) UnmarshalPHP(b []byte) error {
+	var s string
+	if err := Unmarshal(b, &s); err != nil {
+		return err
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	*c = phpCents(math.Round(f * 100))
+	return err
+}
+
+type phpFlags struct {
+	Read, Write bool
+}
+
+func (f *phpFlags) UnmarshalPHP(b []byte) error {
+	var i int
+	if err := Unmarshal(b, &i); err != nil {
+		return err
+	}
+	f.Read, f.Write = i&1 != 0, i&2 != 0
+	return nil
+}
+
+type phpRaw string
+
+func (r *phpRaw) UnmarshalPHP(b []byte) error {
+	*r = phpRaw(b)
+	return nil
+}
+
+func TestPhpUnserializeUnmarshaler(t *testing.T) {
+	assert := assert.New(t)
+	var s struct {
+		Amount phpCents  `php:"amount"`
+		Flags  *phpFlags `php:"flags"`
+		Raw    phpRaw    `php:"raw"`
+		Same   *int      `php:"same"`
+		Nil    *phpFlags `php:"nil"`
+	}
+	s.Nil = &phpFlags{}
+	data := `a:5:{s:6:"amount";s:5:"12.50";s:5:"flags";i:3;s:3:"raw";a:1:{i:0;i:7;}s:4:"same";R:5;s:3:"nil";N;}`
+	assert.NoError(Unmarshal([]byte(data), &s))
+	assert.Equal(phpCents(1250), s.Amount)
+	assert.Equal(&phpFlags{Read: true, Write: true}, s.Flags)
+	assert.Equal(phpRaw(`a:1:{i:0;i:7;}`), s.Raw)
+	assert.Equal(7, *s.Same)
+	assert.Nil(s.Nil)
+
+	var m map[phpRaw]int
+	assert.NoError(Unmarshal([]byte(`a:2:{i:1;i:1;s:1:"a";i:2;}`), &m))
+	assert.Equal(map[phpRaw]int{`i:1;`: 1, `s:1:"a";`: 2}, m)
+
+	var c phpCents
+	assert.Error(Unmarshal([]byte(`i:1;`), &c))
+}
+
+func TestPhpUnserializeTextUnmarshaler(t *testing.T) {
+	assert := assert.New(t)
+	var s struct {
+		Color  phpColor    `php:"color"`
+		IP     net.IP      `php:"ip"`
+		Colors []*phpColor `php:"colors"`
+	}
+	data := `a:3:{s:5:"color";s:7:"#ff0000";s:2:"ip";s:8:"10.0.0.1";s:6:"colors";a:1:{i:0;s:7:"#010203";}}`
+	assert.NoError(Unmarshal([]byte(data), &s))
+	assert.Equal(phpColor{0xff, 0, 0}, s.Color)
+	assert.True(net.IPv4(10, 0, 0, 1).Equal(s.IP))
+	assert.Equal([]*phpColor{{1, 2, 3}}, s.Colors)
+
+	var m map[phpColor]int
+	assert.NoError(Unmarshal([]byte(`a:1:{s:7:"#010203";i:1;}`), &m))
+	assert.Equal(map[phpColor]int{{1, 2, 3}: 1}, m)
+
+	var ips map[string]net.IP
+	assert.Error(Unmarshal([]byte(`a:1:{s:1:"a";s:3:"bad";}`), &ips))
+}
+
+func TestPhpUnserializeBytes(t *testing.T) {
+	assert := assert.New(t)
+	var s struct {
+		B   []byte  `php:"b"`
+		A   [4]byte `php:"a"`
+		Arr []byte  `php:"arr,array"`
+	}
+	data := "a:3:{s:1:\"b\";s:3:\"x\x00y\";s:1:\"a\";s:2:\"yz\";s:3:\"arr\";a:2:{i:0;i:1;i:1;i:2;}}"
+	assert.NoError(Unmarshal([]byte(data), &s))
+	assert.Equal([]byte("x\x00y"), s.B)
+	assert.Equal([4]byte{'y', 'z'}, s.A)
+	assert.Equal([]byte{1, 2}, s.Arr)
+}
+
+func TestPhpUnserializeMaxDepth(t *testing.T) {
+	assert := assert.New(t)
+	deep := strings.Repeat("a:1:{i:0;", defaultMaxDepth+1) + "N;" + strings.Repeat("}", defaultMaxDepth+1)
+	var v interface{}
+	var syntaxErr *SyntaxError
+	assert.True(errors.As(Unmarshal([]byte(deep), &v), &syntaxErr))
+
+	dec := NewDecoder(strings.NewReader(`a:1:{i:0;a:1:{i:0;a:0:{}}}`))
+	dec.SetMaxDepth(2)
+	assert.True(errors.As(dec.Decode(&v), &syntaxErr))
+
+	dec = NewDecoder(strings.NewReader(deep))
+	dec.SetMaxDepth(-1)
+	assert.NoError(dec.Decode(&v))
+
+	// reference to skipped array from inside of it
+	var s struct{ B interface{} }
+	assert.True(errors.As(Unmarshal([]byte(`a:2:{s:1:"X";a:1:{i:0;R:2;}s:1:"B";R:2;}`), &s), &syntaxErr))
+}
+
+func TestPhpUnserializeBigNumbers(t *testing.T) {
+	assert := assert.New(t)
+	var s struct {
+		Int   *big.Int    `php:"int"`
+		Float big.Float   `php:"float"`
+		Rat   *big.Rat    `php:"rat"`
+		Num   json.Number `php:"num"`
+		Str   *big.Int    `php:"str"`
+	}
+	data := `a:5:{s:3:"int";i:123456789012345678901234567890;s:5:"float";d:1.5;s:3:"rat";d:0.25;s:3:"num";i:99999999999999999999;s:3:"str";s:3:"-12";}`
+	assert.NoError(Unmarshal([]byte(data), &s))
+	assert.Equal("123456789012345678901234567890", s.Int.String())
+	assert.Equal("1.5", s.Float.Text('g', -1))
+	assert.Equal("1/4", s.Rat.RatString())
+	assert.Equal(json.Number("99999999999999999999"), s.Num)
+	assert.Equal(int64(-12), s.Str.Int64())
+
+	var v interface{}
+	assert.NoError(Unmarshal([]byte(`i:123456789012345678901234567890;`), &v))
+	assert.Equal("123456789012345678901234567890", v.(*big.Int).String())
+	var f float64
+	assert.NoError(Unmarshal([]byte(`i:100000000000000000000;`), &f))
+	assert.Equal(1e20, f)
+	var i int64
+	assert.Error(Unmarshal([]byte(`i:100000000000000000000;`), &i))
+}
+
+func TestPhpUnserializeTime(t *testing.T) {
+	assert := assert.New(t)
+	expected := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	data := []string{
+		`i:1704164645;`,
+		`d:1704164645;`,
+		`s:20:"2024-01-02T03:04:05Z";`,
+		`s:19:"2024-01-02 03:04:05";`,
+		`O:8:"DateTime":3:{s:4:"date";s:26:"2024-01-02 03:04:05.000000";s:13:"timezone_type";i:3;s:8:"timezone";s:3:"UTC";}`,
+		`O:17:"DateTimeImmutable":3:{s:4:"date";s:26:"2024-01-02 05:04:05.000000";s:13:"timezone_type";i:1;s:8:"timezone";s:6:"+02:00";}`,
+	}
+	for _, tt := range data {
+		var tm time.Time
+		assert.NoError(Unmarshal([]byte(tt), &tm), tt)
+		assert.True(expected.Equal(tm), "%s: %v", tt, tm)
+	}
+
+	var s struct {
+		At *time.Time `php:"at"`
+	}
+	assert.NoError(Unmarshal([]byte(`a:1:{s:2:"at";i:1704164645;}`), &s))
+	assert.True(expected.Equal(*s.At))
+
+	dec := NewDecoder(bytes.NewBufferString(`s:10:"2024-01-02";`))
+	dec.SetTimeLayout("2006-01-02")
+	var tm time.Time
+	assert.NoError(dec.Decode(&tm))
+	assert.Equal(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), tm)
+
+	assert.Error(Unmarshal([]byte(`b:1;`), &tm))
+	assert.Error(Unmarshal([]byte(`s:3:"bad";`), &tm))
+	assert.Error(Unmarshal([]byte(`O:3:"Foo":0:{}`), &tm))
+
+	for _, format := range []DurationEncoding{DurationNanoseconds, DurationSeconds, DurationString} {
+		v := struct {
+			D time.Duration  `php:"d"`
+			P *time.Duration `php:"p"`
+		}{D: 90*time.Minute + 1500*time.Millisecond, P: new(time.Duration)}
+		*v.P = -time.Nanosecond
+		b, err := MarshalWithOptions(v, DurationFormat(format))
+		assert.NoError(err)
+		var got struct {
+			D time.Duration  `php:"d"`
+			P *time.Duration `php:"p"`
+		}
+		assert.NoError(Unmarshal(b, &got), string(b))
+		assert.Equal(v.D, got.D, string(b))
+		if assert.NotNil(got.P) {
+			assert.Equal(*v.P, *got.P, string(b))
+		}
+	}
+	var dur time.Duration
+	assert.NoError(Unmarshal([]byte(`d:5400;`), &dur))
+	assert.Equal(90*time.Minute, dur)
+	assert.NoError(Unmarshal([]byte(`s:7:"1h30m0s";`), &dur))
+	assert.Equal(90*time.Minute, dur)
+	assert.Error(Unmarshal([]byte(`s:3:"bad";`), &dur))
+	assert.Error(Unmarshal([]byte(`d:1e300;`), &dur))
+}
@@ -1,5 +1,15 @@
-// Package phpserialize is implementation of php serialize function
+// Package phpserialize is implementation of php serialize and unserialize functions
 // based on github.com/mitsuhiko/phpserialize
+//
+// Types implementing AppendMarshaler or Marshaler write themselves and
+// encoder options don't apply to what they write. This holds for methods
+// generated by phpserialize-gen too: they write what Marshal with default
+// options does, regardless of FloatPrecision, StructsAsObjects, TagNames,
+// SortMapKeys, TimeFormat, DurationFormat or BigNumbers set on the encoder.
+// Generated code writes no php references and refuses values reaching the
+// same pointer twice, generated UnmarshalPHP refuses references as well
+// since it can't resolve them. Pointers to generated types shared between
+// values written by the reflective encoder are still written as references
 package phpserialize
 
 import (
@@ -18,6 +28,11 @@ func (e *encoderPool) Get() *Encoder {
 	enc := e.Pool.Get().(*Encoder)
 	enc.Reset()
 	enc.w = nil
+	enc.hold = 0
+	enc.err = nil
+	enc.encOpts = encOpts{}
+	enc.ownState.reset()
+	enc.state = &enc.ownState
 	return enc
 }
 
@@ -42,6 +57,119 @@ type Encoder struct {
 	bytes.Buffer
 	scratch scratchBuffer
 	w       io.Writer
+	encOpts
+	state    *encState
+	ownState encState
+	// hold is greater than zero while written output may still change
+	// and so can not be flushed
+	hold int
+	// err is error of writing flushed output
+	err error
+}
+
+// encState is state of encoding single value
+type encState struct {
+	encRefs
+	depth int
+	// seen holds maps and slices being encoded once nesting gets deep
+	seen map[cycleKey]struct{}
+}
+
+func (s *encState) reset() {
+	s.encRefs.reset()
+	s.depth = 0
+	for k := range s.seen {
+		delete(s.seen, k)
+	}
+}
+
+const (
+	// defaultMaxDepth is nesting limit of encoder and decoder
+	// when none is set, it keeps stack from overflowing
+	defaultMaxDepth = 10000
+	// startDetectingCyclesAfter is depth cycle detection starts at,
+	// it costs map lookup for each nested map or slice
+	startDetectingCyclesAfter = 1000
+)
+
+// cycleKey identifies map or slice, slices sharing array
+// differ by length
+type cycleKey struct {
+	ptr uintptr
+	len int
+}
+
+// maxDepthOf returns effective depth limit for depth setting
+func maxDepthOf(depth int) int {
+	if depth == 0 {
+		return defaultMaxDepth
+	}
+	return depth
+}
+
+// enter is called when encoder descends into array or object
+func (e *Encoder) enter(v reflect.Value) error {
+	e.state.depth++
+	if max := maxDepthOf(e.maxDepth); max > 0 && e.state.depth > max {
+		return &UnsupportedValueError{Value: v, Str: fmt.Sprintf("exceeded max depth of %d", max), pathless: true}
+	}
+	if e.state.depth > startDetectingCyclesAfter {
+		if k, ok := cycleKeyOf(v); ok {
+			if _, ok := e.state.seen[k]; ok {
+				return &UnsupportedValueError{Value: v, Str: fmt.Sprintf("encountered a cycle via %s", v.Type()), pathless: true}
+			}
+			if e.state.seen == nil {
+				e.state.seen = make(map[cycleKey]struct{})
+			}
+			e.state.seen[k] = struct{}{}
+		}
+	}
+	return nil
+}
+
+func (e *Encoder) leave(v reflect.Value) {
+	if e.state.depth > startDetectingCyclesAfter {
+		if k, ok := cycleKeyOf(v); ok {
+			delete(e.state.seen, k)
+		}
+	}
+	e.state.depth--
+}
+
+func cycleKeyOf(v reflect.Value) (cycleKey, bool) {
+	switch v.Kind() {
+	case reflect.Map:
+		return cycleKey{ptr: v.Pointer()}, !v.IsNil()
+	case reflect.Slice:
+		return cycleKey{ptr: v.Pointer(), len: v.Len()}, !v.IsNil()
+	}
+	return cycleKey{}, false
+}
+
+// flush writes buffered output to the writer once there is more
+// of it than buffer size set with BufferSize
+func (e *Encoder) flush() error {
+	if e.w == nil || e.bufferSize <= 0 || e.hold > 0 || e.Len() < e.bufferSize {
+		return nil
+	}
+	// flushed output can't be scanned later
+	e.state.count(e.Bytes())
+	_, e.err = e.WriteTo(e.w)
+	return e.err
+}
+
+// SetSortMapKeys makes encoder write go map keys in order php ksort
+// would put them, integers numerically first and then strings
+func (e *Encoder) SetSortMapKeys(sort bool) {
+	e.sortMapKeys = sort
+}
+
+// SetFloatPrecision sets number of significant digits floats are written
+// with, like php serialize_precision ini setting does. Precision -1 is the
+// default of php 7.1+ which writes shortest representation reading back to
+// the same value, older php versions used 17
+func (e *Encoder) SetFloatPrecision(precision int) {
+	e.floatPrecision = precision
 }
 
 type encoderProp struct {
@@ -49,23 +177,26 @@ type encoderProp struct {
 	value reflect.Value
 }
 
-func (e *Encoder) encodeKey(v reflect.Value) error {
-	switch {
-	case isNil(v):
-		e.WriteString(`s:0:"";`)
-		return nil
-	case isInteger(v):
-		return e.encodeInteger(v)
-	case isFloating(v):
-		return e.encodeFloating(v)
-	case isString(v):
-		return e.encodeString(v)
-	default:
-		return TypeError{fmt.Errorf("can't serialize %v as key", v.Interface())}
+func (e *Encoder) encodeKey(v reflect.Value, numeric bool) error {
+	k, err := toPhpKey(v, numeric)
+	if err != nil {
+		return err
 	}
+	e.writeKey(k)
+	return nil
 }
 
 func (e *Encoder) encodeValue(v reflect.Value) error {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return e.encodeNil(v)
+	}
+	// shared pointers are references even when their values marshal themselves
+	if v.Kind() == reflect.Ptr && e.encodeRef(v) {
+		return nil
+	}
+	if v.Type().Implements(appendMarshalerType) {
+		return e.encodeAppendMarshaler(v)
+	}
 	if v.Type().Implements(marshalerType) {
 		return e.encodeMarshaler(v)
 	}
@@ -73,7 +204,21 @@ func (e *Encoder) encodeValue(v reflect.Value) error {
 		return e.encodeNil(v)
 	}
 	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
-		v = v.Elem()
+		return e.encodeValue(v.Elem())
+	}
+	if info, ok := registeredEnum(v.Type()); ok {
+		return e.encodeRegisteredEnum(v, info)
+	}
+	switch v.Type() {
+	case timeType:
+		return e.encodeTime(v)
+	case durationType:
+		return e.encodeDuration(v)
+	case bigIntType, bigFloatType, bigRatType, numberType:
+		return e.encodeNumber(v)
+	}
+	if m, ok := textMarshaler(v); ok {
+		return e.encodeTextMarshaler(v, m)
 	}
 	switch {
 	case isBool(v):
@@ -84,16 +229,30 @@ func (e *Encoder) encodeValue(v reflect.Value) error {
 		return e.encodeFloating(v)
 	case isString(v):
 		return e.encodeString(v)
+	case isOrderedArray(v):
+		return e.encodeOrderedArray(v)
+	case isBytes(v):
+		return e.encodeBytes(v)
 	case isIterable(v):
 		return e.encodeIterable(v)
 	case isMap(v):
 		return e.encodeMap(v)
-	case isStruct(v):
-		return e.encodeStruct(v)
+	case isEnum(v):
+		return e.encodeEnum(v)
+	case isSerializable(v):
+		return e.encodeSerializable(v)
+	case isCustomObject(v):
+		return e.encodeCustomObject(v)
 	case isPhpObject(v):
 		return e.encodePhpObject(v)
+	case isObject(v):
+		return e.encodeObject(v)
+	case isReference(v):
+		return e.encodeReference(v)
+	case isStruct(v):
+		return e.encodeStruct(v)
 	}
-	return TypeError{fmt.Errorf("can't serialize %v", v.Interface())}
+	return &UnsupportedTypeError{v.Type()}
 }
 
 func (e *Encoder) encodePropsHeader(l int) error {
@@ -112,34 +271,55 @@ func (e *Encoder) encodeProp(prop encoderProp) error {
 	if err := prop.key(); err != nil {
 		return err
 	}
+	e.state.n++
 	return e.encodeValue(prop.value)
 }
 
-// Encode value in php serialize format
+// Encode value in php serialize format, with BufferSize option
+// part of the value may be written before an error is returned
 func (e *Encoder) Encode(v interface{}) error {
+	e.state.reset()
+	e.err = nil
+	e.state.n++
 	if err := e.encodeValue(reflect.ValueOf(v)); err != nil {
+		e.Reset()
+		if e.err != nil {
+			// writer error is not caused by the value at path
+			return e.err
+		}
 		return err
 	}
 	_, err := io.Copy(e.w, e)
 	return err
 }
 
-// NewEncoder creates new encoder
-func NewEncoder(w io.Writer) *Encoder {
+// NewEncoder creates new encoder writing to w
+func NewEncoder(w io.Writer, opts ...EncoderOption) *Encoder {
 	enc := encoderStatePool.Get()
 	enc.w = w
+	for _, opt := range opts {
+		opt(&enc.encOpts)
+	}
 	return enc
 }
 
 // Marshal v like Php serialize function
 func Marshal(v interface{}) ([]byte, error) {
+	return MarshalWithOptions(v)
+}
+
+// MarshalWithOptions marshals v like Marshal using encoder options
+func MarshalWithOptions(v interface{}, opts ...EncoderOption) ([]byte, error) {
 	enc := encoderStatePool.Get()
+	defer encoderStatePool.Put(enc)
+	for _, opt := range opts {
+		opt(&enc.encOpts)
+	}
+	enc.state.n++
 	if err := enc.encodeValue(reflect.ValueOf(v)); err != nil {
 		return nil, err
 	}
-	b := append([]byte(nil), enc.Bytes()...)
-	encoderStatePool.Put(enc)
-	return b, nil
+	return append([]byte(nil), enc.Bytes()...), nil
 }
 
 func isEmptyValue(v reflect.Value) bool {
@@ -159,3 +339,14 @@ func isEmptyValue(v reflect.Value) bool {
 	}
 	return false
 }
+
+// addressable returns v if it is addressable or its addressable copy
+// so that methods with pointer receivers can be called on it
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	p := reflect.New(v.Type())
+	p.Elem().Set(v)
+	return p.Elem()
+}
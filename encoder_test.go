@@ -1,9 +1,19 @@
 package phpserialize
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"net"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -25,15 +35,18 @@ func TestPhpSerialize(t *testing.T) {
 		{5, "i:5;"},
 		{5.6, "d:5.6;"},
 		{"Hello world", `s:11:"Hello world";`},
-		{"Björk Guðmundsdóttir", `s:23:"Bj\xc3\xb6rk Gu\xc3\xb0mundsd\xc3\xb3ttir";`},
+		{"Björk Guðmundsdóttir", "s:23:\"Bj\xc3\xb6rk Gu\xc3\xb0mundsd\xc3\xb3ttir\";"},
 		{`Hello
-world`, `s:11:"Hello\nworld";`},
-		{"\001\002\003", `s:3:"\x01\x02\x03";`},
+world`, "s:11:\"Hello\nworld\";"},
+		{"\001\002\003", "s:3:\"\x01\x02\x03\";"},
 		{[]int{7, 8, 9}, "a:3:{i:0;i:7;i:1;i:8;i:2;i:9;}"},
 		{PhpTagged{AField: 1}, `a:1:{s:7:"a_field";i:1;}`},
 		{PhpTagged{AField: 1, BField: 2}, `a:2:{s:7:"a_field";i:1;s:7:"b_field";i:2;}`},
 		{JsonTagged{AField: 1}, `a:1:{s:7:"a_field";i:1;}`},
 		{JsonTagged{AField: 1, BField: 2}, `a:2:{s:7:"a_field";i:1;s:7:"b_field";i:2;}`},
+		{OrderedArray{{Key: "b", Value: 1}, {Key: int64(0), Value: nil}}, `a:2:{s:1:"b";i:1;i:0;N;}`},
+		{&Object{Class: "Foo", Properties: OrderedArray{{Key: "a", Value: "b"}}}, `O:3:"Foo":1:{s:1:"a";s:1:"b";}`},
+		{PhpObject{Name: "Foo", PhpVars: []int{1}}, `O:3:"Foo":1:{i:0;i:1;}`},
 	}
 	for _, tt := range data {
 		b, err := Marshal(tt.v)
@@ -51,6 +64,47 @@ world`, `s:11:"Hello\nworld";`},
 	assert.NotEqual(strings.Index(ms, `s:1:"b";i:2;`), -1)
 	assert.NotEqual(strings.Index(ms, `s:1:"c";i:3;`), -1)
 	assert.Len(ms, 42)
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	enc.SetSortMapKeys(true)
+	assert.NoError(enc.Encode(m))
+	assert.Equal(`a:3:{s:1:"a";i:1;s:1:"b";i:2;s:1:"c";i:3;}`, buf.String())
+
+	buf.Reset()
+	assert.NoError(enc.Encode(map[interface{}]int{"b": 1, 10: 2, "a": 3, -1: 4, 2.5: 5}))
+	assert.Equal(`a:5:{i:-1;i:4;i:2;i:5;i:10;i:2;s:1:"a";i:3;s:1:"b";i:1;}`, buf.String())
+
+	buf.Reset()
+	assert.NoError(enc.Encode(struct {
+		M map[int]bool `php:"m"`
+	}{map[int]bool{3: true, 1: false, 2: true}}))
+	assert.Equal(`a:1:{s:1:"m";a:3:{i:1;b:0;i:2;b:1;i:3;b:1;}}`, buf.String())
+}
+
+func TestOrderedArray(t *testing.T) {
+	assert := assert.New(t)
+	var arr OrderedArray
+	arr.Set("z", 1)
+	arr.Set(5, "five")
+	arr.Set("a", true)
+	arr.Set(int64(5), "FIVE")
+	v, ok := arr.Get(5)
+	assert.True(ok)
+	assert.Equal("FIVE", v)
+	arr.Delete("z")
+	_, ok = arr.Get("z")
+	assert.False(ok)
+	b, err := Marshal(arr)
+	assert.NoError(err)
+	assert.Equal(`a:2:{i:5;s:4:"FIVE";s:1:"a";b:1;}`, string(b))
+
+	// keys php arrays can't have are never found
+	arr.Set([]int{1}, "slice")
+	_, ok = arr.Get([]int{1})
+	assert.False(ok)
+	arr.Delete(map[string]int{})
+	assert.Len(arr, 3)
 }
 
 var bb []byte
@@ -105,6 +159,14 @@ func BenchmarkJSONSerializeSlice(b *testing.B) {
 	benchmarkJsonSerialize([]int{7, 8, 9}, b)
 }
 
+func BenchmarkPhpSerializeMarshaler(b *testing.B) {
+	benchmarkPhpSerialize([]rawMarshaler{`i:1;`, `i:2;`, `i:3;`}, b)
+}
+
+func BenchmarkPhpSerializeAppendMarshaler(b *testing.B) {
+	benchmarkPhpSerialize([]phpID{1, 2, 3}, b)
+}
+
 func BenchmarkPhpSerializeStruct(b *testing.B) {
 	benchmarkPhpSerialize(struct {
 		AField int `php:"a_field"`
@@ -132,3 +194,734 @@ func BenchmarkPhpSerializeMap(b *testing.B) {
 func BenchmarkJSONSerializeMap(b *testing.B) {
 	benchmarkJsonSerialize(map[string]interface{}{"a": 1, "b": 2, "c": 3}, b)
 }
+
+type refMarshaler struct{}
+
+func (refMarshaler) MarshalPHP() ([]byte, error) {
+	return []byte("a:1:{i:0;i:1;}"), nil
+}
+
+func TestPhpSerializeReferences(t *testing.T) {
+	assert := assert.New(t)
+	type Node struct {
+		Name string
+		Next *Node
+	}
+	obj := &Object{Class: "Foo", Properties: OrderedArray{{Key: "a", Value: 1}}}
+	i := 5
+	n := &Node{Name: "a"}
+	n.Next = n
+	data := []struct {
+		v        interface{}
+		expected string
+	}{
+		{[]*Object{obj, obj}, `a:2:{i:0;O:3:"Foo":1:{s:1:"a";i:1;}i:1;r:2;}`},
+		{struct{ A, B *int }{&i, &i}, `a:2:{s:1:"A";i:5;s:1:"B";R:2;}`},
+		{n, `a:2:{s:4:"Name";s:1:"a";s:4:"Next";R:1;}`},
+		{[]interface{}{refMarshaler{}, obj, obj}, `a:3:{i:0;a:1:{i:0;i:1;}i:1;O:3:"Foo":1:{s:1:"a";i:1;}i:2;r:4;}`},
+	}
+	for _, tt := range data {
+		b, err := Marshal(tt.v)
+		assert.NoError(err)
+		assert.Equal(tt.expected, string(b))
+	}
+}
+
+type phpUser struct {
+	Name    string     `php:"name"`
+	Friend  *phpUser   `php:"friend,omitempty"`
+	Address phpAddress `php:"address"`
+}
+
+func (phpUser) PHPClassName() string {
+	return `App\User`
+}
+
+type phpAddress struct {
+	City string `php:"city"`
+}
+
+func (*phpAddress) PHPClassName() string {
+	return "Address"
+}
+
+func TestPhpSerializeClassName(t *testing.T) {
+	assert := assert.New(t)
+	u := &phpUser{Name: "a", Address: phpAddress{City: "b"}}
+	b, err := Marshal(u)
+	assert.NoError(err)
+	assert.Equal(`O:8:"App\User":2:{s:4:"name";s:1:"a";s:7:"address";O:7:"Address":1:{s:4:"city";s:1:"b";}}`, string(b))
+
+	// pointer receiver class name applies to values which are not addressable too
+	b, err = Marshal(phpUser{Name: "a"})
+	assert.NoError(err)
+	assert.Equal(`O:8:"App\User":2:{s:4:"name";s:1:"a";s:7:"address";O:7:"Address":1:{s:4:"city";s:0:"";}}`, string(b))
+	b, err = Marshal(phpAddress{City: "c"})
+	assert.NoError(err)
+	assert.Equal(`O:7:"Address":1:{s:4:"city";s:1:"c";}`, string(b))
+
+	u.Friend = u
+	b, err = Marshal(u)
+	assert.NoError(err)
+	assert.Equal(`O:8:"App\User":3:{s:4:"name";s:1:"a";s:6:"friend";r:1;s:7:"address";O:7:"Address":1:{s:4:"city";s:1:"b";}}`, string(b))
+}
+
+type phpBase struct {
+	ID int `php:"id,private"`
+}
+
+func (phpBase) PHPClassName() string {
+	return "Base"
+}
+
+type phpEntity struct {
+	phpBase
+	Name   string `php:"name,protected"`
+	Secret string `php:"secret,private"`
+	Public int    `php:"public"`
+}
+
+func (phpEntity) PHPClassName() string {
+	return "Entity"
+}
+
+func TestPhpSerializeVisibility(t *testing.T) {
+	assert := assert.New(t)
+	b, err := Marshal(phpEntity{phpBase: phpBase{ID: 7}, Name: "n", Secret: "s", Public: 1})
+	assert.NoError(err)
+	assert.Equal("O:6:\"Entity\":4:{s:7:\"\x00*\x00name\";s:1:\"n\";s:14:\"\x00Entity\x00secret\";s:1:\"s\";s:6:\"public\";i:1;s:8:\"\x00Base\x00id\";i:7;}", string(b))
+
+	// private properties of structs without class name belong to the class written
+	type priv struct {
+		A int `php:"a,private"`
+		B int `php:"7,private"`
+	}
+	b, err = Marshal(priv{A: 1, B: 2})
+	assert.NoError(err)
+	assert.Equal(`a:2:{s:1:"a";i:1;i:7;i:2;}`, string(b))
+	b, err = MarshalWithOptions(priv{A: 1, B: 2}, StructsAsObjects(true))
+	assert.NoError(err)
+	assert.Equal("O:8:\"stdClass\":2:{s:11:\"\x00stdClass\x00a\";i:1;s:11:\"\x00stdClass\x007\";i:2;}", string(b))
+	var p priv
+	assert.NoError(Unmarshal(b, &p))
+	assert.Equal(priv{A: 1, B: 2}, p)
+}
+
+type phpMoney struct {
+	Amount   int64
+	Currency string
+}
+
+func (*phpMoney) PHPClassName() string {
+	return "Money"
+}
+
+func (m *phpMoney) SerializePHP() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d %s", m.Amount, m.Currency)), nil
+}
+
+func (m *phpMoney) UnserializePHP(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "%d %s", &m.Amount, &m.Currency)
+	return err
+}
+
+func TestPhpSerializeSerializable(t *testing.T) {
+	assert := assert.New(t)
+	m := &phpMoney{Amount: 100, Currency: "EUR"}
+	b, err := Marshal([]interface{}{m, m, CustomObject{Class: "Foo", Data: []byte("x")}})
+	assert.NoError(err)
+	assert.Equal(`a:3:{i:0;C:5:"Money":7:{100 EUR}i:1;r:2;i:2;C:3:"Foo":1:{x}}`, string(b))
+
+	b, err = Marshal(struct {
+		M phpMoney `php:"m"`
+	}{phpMoney{Amount: 1, Currency: "USD"}})
+	assert.NoError(err)
+	assert.Equal(`a:1:{s:1:"m";C:5:"Money":5:{1 USD}}`, string(b))
+	b, err = Marshal(phpMoney{Amount: 2, Currency: "GBP"})
+	assert.NoError(err)
+	assert.Equal(`C:5:"Money":5:{2 GBP}`, string(b))
+}
+
+type phpSuit int
+
+const (
+	phpHearts phpSuit = iota
+	phpSpades
+)
+
+func init() {
+	RegisterEnum("Suit", map[string]interface{}{"Hearts": phpHearts, "Spades": phpSpades})
+}
+
+func TestPhpSerializeEnum(t *testing.T) {
+	assert := assert.New(t)
+	b, err := Marshal(struct {
+		Suit  phpSuit     `php:"suit"`
+		Other interface{} `php:"other"`
+	}{phpSpades, Enum{Class: "Status", Case: "Active"}})
+	assert.NoError(err)
+	assert.Equal(`a:2:{s:4:"suit";E:11:"Suit:Spades";s:5:"other";E:13:"Status:Active";}`, string(b))
+
+	b, err = Marshal([]phpSuit{phpHearts})
+	assert.NoError(err)
+	assert.Equal(`a:1:{i:0;E:11:"Suit:Hearts";}`, string(b))
+
+	_, err = Marshal(phpSuit(7))
+	assert.Error(err)
+}
+
+func TestPhpSerializeFloating(t *testing.T) {
+	assert := assert.New(t)
+	// expected values are php 7.1+ serialize() output
+	data := []struct {
+		v        interface{}
+		expected string
+	}{
+		{math.Inf(1), "d:INF;"},
+		{math.Inf(-1), "d:-INF;"},
+		{math.NaN(), "d:NAN;"},
+		{1e25, "d:1.0E+25;"},
+		{-1.5e-7, "d:-1.5E-7;"},
+		{1e17, "d:1.0E+17;"},
+		{1e16, "d:10000000000000000;"},
+		{123456789012345678.0, "d:1.2345678901234568E+17;"},
+		{0.0001, "d:0.0001;"},
+		{0.00001, "d:1.0E-5;"},
+		{0.30000000000000004, "d:0.30000000000000004;"},
+		{100.0, "d:100;"},
+		{0.5, "d:0.5;"},
+		{-12.25, "d:-12.25;"},
+		{0.0, "d:0;"},
+		{math.Copysign(0, -1), "d:-0;"},
+		{float32(5.6), "d:5.6;"},
+		{struct {
+			F float64 `php:"f,string"`
+		}{math.Inf(-1)}, `a:1:{s:1:"f";s:4:"-INF";}`},
+		{struct {
+			F float64 `php:"f,string"`
+		}{2.5e30}, `a:1:{s:1:"f";s:7:"2.5E+30";}`},
+	}
+	for _, tt := range data {
+		b, err := Marshal(tt.v)
+		assert.NoError(err)
+		assert.Equal(tt.expected, string(b))
+	}
+}
+
+func TestPhpSerializeFloatPrecision(t *testing.T) {
+	assert := assert.New(t)
+	data := []struct {
+		precision int
+		v         interface{}
+		expected  string
+	}{
+		{17, 0.1, "d:0.10000000000000001;"},
+		{17, 5.6, "d:5.5999999999999996;"},
+		{17, 100.0, "d:100;"},
+		{17, 1e25, "d:1.0000000000000001E+25;"},
+		{17, 0.0, "d:0;"},
+		{14, 1e15, "d:1.0E+15;"},
+		{14, 0.1, "d:0.1;"},
+		{14, []float64{1.0 / 3}, "a:1:{i:0;d:0.33333333333333;}"},
+		{-1, 0.1, "d:0.1;"},
+	}
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf)
+	for _, tt := range data {
+		buf.Reset()
+		enc.SetFloatPrecision(tt.precision)
+		assert.NoError(enc.Encode(tt.v))
+		assert.Equal(tt.expected, buf.String())
+	}
+}
+
+func TestPhpSerializeOptions(t *testing.T) {
+	assert := assert.New(t)
+	type Tagged struct {
+		A int `php:"php_a" json:"json_a"`
+		B int `json:"json_b"`
+	}
+	type Nested struct {
+		Tagged Tagged         `php:"tagged"`
+		M      map[string]int `php:"m"`
+	}
+	v := Nested{Tagged{1, 2}, map[string]int{"y": 2, "x": 1}}
+
+	b, err := MarshalWithOptions(v, SortMapKeys(true), StructsAsObjects(true))
+	assert.NoError(err)
+	assert.Equal(`O:8:"stdClass":2:{s:6:"tagged";O:8:"stdClass":2:{s:5:"php_a";i:1;s:6:"json_b";i:2;}s:1:"m";a:2:{s:1:"x";i:1;s:1:"y";i:2;}}`, string(b))
+
+	b, err = MarshalWithOptions(v.Tagged, TagNames("json"))
+	assert.NoError(err)
+	assert.Equal(`a:2:{s:6:"json_a";i:1;s:6:"json_b";i:2;}`, string(b))
+
+	b, err = MarshalWithOptions(v.Tagged, TagNames("yaml"))
+	assert.NoError(err)
+	assert.Equal(`a:2:{s:1:"A";i:1;s:1:"B";i:2;}`, string(b))
+
+	_, err = MarshalWithOptions(v, MaxDepth(1))
+	assert.Error(err)
+	_, err = MarshalWithOptions(v, MaxDepth(2))
+	assert.NoError(err)
+
+	var buf bytes.Buffer
+	enc := NewEncoder(&buf, FloatPrecision(17), SortMapKeys(true))
+	assert.NoError(enc.Encode(map[string]float64{"b": 0.1, "a": 1}))
+	assert.Equal(`a:2:{s:1:"a";d:1;s:1:"b";d:0.10000000000000001;}`, buf.String())
+}
+
+func TestPhpSerializeErrorPath(t *testing.T) {
+	assert := assert.New(t)
+	type Address struct {
+		Zip interface{} `php:"zip"`
+	}
+	type User struct {
+		Address Address `php:"address"`
+	}
+	type Root struct {
+		Users []User `php:"users"`
+	}
+	data := []struct {
+		v    interface{}
+		path string
+	}{
+		{Root{Users: []User{{}, {}, {}, {Address: Address{Zip: make(chan int)}}}}, "users[3].address.zip"},
+		{[]interface{}{1, map[string]interface{}{"k": func() {}}}, "[1][k]"},
+		{OrderedArray{{Key: "a", Value: []complex64{1}}}, "[a][0]"},
+		{struct{ C chan int }{make(chan int)}, "C"},
+	}
+	for _, tt := range data {
+		b, err := Marshal(tt.v)
+		assert.Nil(b)
+		var pathErr *PathError
+		if assert.True(errors.As(err, &pathErr), "%v", err) {
+			assert.Equal(tt.path, pathErr.Path)
+		}
+		var typeErr *UnsupportedTypeError
+		assert.True(errors.As(err, &typeErr))
+	}
+
+	_, err := Marshal([]interface{}{errMarshaler{}})
+	var marshalerErr *MarshalerError
+	if assert.True(errors.As(err, &marshalerErr)) {
+		assert.Equal(reflect.TypeOf(errMarshaler{}), marshalerErr.Type)
+		assert.Equal(io.ErrShortWrite, marshalerErr.Err)
+	}
+	assert.True(errors.Is(err, io.ErrShortWrite))
+
+	_, err = MarshalWithOptions([]interface{}{[]int{1}}, MaxDepth(1))
+	var valueErr *UnsupportedValueError
+	assert.True(errors.As(err, &valueErr))
+}
+
+func TestPhpSerializeValidateMarshalers(t *testing.T) {
+	assert := assert.New(t)
+	data := []rawMarshaler{`s:3:"ab";`, `i:1`, `i:1;i:2;`, ``}
+	for _, tt := range data {
+		b, err := MarshalWithOptions([]interface{}{tt}, ValidateMarshalers(true))
+		assert.Nil(b)
+		var marshalerErr *MarshalerError
+		if assert.True(errors.As(err, &marshalerErr), "%q", tt) {
+			assert.Equal(reflect.TypeOf(tt), marshalerErr.Type)
+		}
+	}
+
+	b, err := MarshalWithOptions(rawMarshaler(`a:1:{i:0;s:1:"a";}`), ValidateMarshalers(true))
+	assert.NoError(err)
+	assert.Equal(`a:1:{i:0;s:1:"a";}`, string(b))
+	b, err = Marshal(rawMarshaler(`i:1`))
+	assert.NoError(err)
+	assert.Equal(`i:1`, string(b))
+}
+
+type rawMarshaler string
+
+func (m rawMarshaler) MarshalPHP() ([]byte, error) {
+	return []byte(m), nil
+}
+
+func TestPhpSerializeAppendMarshaler(t *testing.T) {
+	assert := assert.New(t)
+	id := phpID(7)
+	b, err := Marshal([]interface{}{phpID(1), &id})
+	assert.NoError(err)
+	assert.Equal(`a:2:{i:0;i:1;i:1;i:7;}`, string(b))
+
+	b, err = Marshal([]*phpID{&id, &id})
+	assert.NoError(err)
+	assert.Equal(`a:2:{i:0;i:7;i:1;R:2;}`, string(b))
+
+	b, err = Marshal(struct {
+		ID    phpID `php:"id"`
+		Other *int  `php:"other"`
+	}{ID: 2})
+	assert.NoError(err)
+	assert.Equal(`a:2:{s:2:"id";i:2;s:5:"other";N;}`, string(b))
+
+	// values nested in marshaled arrays are numbered for later references
+	arr := rawMarshaler(`a:2:{i:0;i:1;i:1;i:2;}`)
+	x, y := 1, 2
+	v := []interface{}{arr, &x, arr, &y, &x, &y, Reference{Slot: 8}}
+	expected := `a:7:{i:0;a:2:{i:0;i:1;i:1;i:2;}i:1;i:1;i:2;a:2:{i:0;i:1;i:1;i:2;}i:3;i:2;i:4;R:5;i:5;R:9;i:6;R:8;}`
+	b, err = Marshal(v)
+	assert.NoError(err)
+	assert.Equal(expected, string(b))
+	var w bytes.Buffer
+	assert.NoError(NewEncoder(&w, BufferSize(1)).Encode(v))
+	assert.Equal(expected, w.String())
+
+	_, err = Marshal(phpID(-1))
+	var marshalerErr *MarshalerError
+	if assert.True(errors.As(err, &marshalerErr)) {
+		assert.Contains(marshalerErr.Error(), "AppendPHP")
+	}
+}
+
+type numericFields struct {
+	A int `php:"12"`
+	B int `php:"012"`
+}
+
+func TestPhpSerializeArrayKeys(t *testing.T) {
+	assert := assert.New(t)
+	data := []struct {
+		v        interface{}
+		expected string
+	}{
+		{map[string]int{"12": 1}, `a:1:{i:12;i:1;}`},
+		{map[string]int{"-3": 1}, `a:1:{i:-3;i:1;}`},
+		{map[string]int{"012": 1}, `a:1:{s:3:"012";i:1;}`},
+		{map[string]int{"-0": 1}, `a:1:{s:2:"-0";i:1;}`},
+		{map[string]int{"9223372036854775808": 1}, `a:1:{s:19:"9223372036854775808";i:1;}`},
+		{map[float64]int{1.9: 1}, `a:1:{i:1;i:1;}`},
+		{map[float64]int{-2.5: 1}, `a:1:{i:-2;i:1;}`},
+		{map[float64]int{math.Inf(1): 1}, `a:1:{i:0;i:1;}`},
+		{map[bool]int{true: 1}, `a:1:{i:1;i:1;}`},
+		{map[interface{}]int{nil: 1}, `a:1:{s:0:"";i:1;}`},
+		{OrderedArray{{Key: "7", Value: 1}, {Key: false, Value: 2}}, `a:2:{i:7;i:1;i:0;i:2;}`},
+		{Object{Class: "Foo", Properties: OrderedArray{{Key: "7", Value: 1}}}, `O:3:"Foo":1:{s:1:"7";i:1;}`},
+		{numericFields{A: 1, B: 2}, `a:2:{i:12;i:1;s:3:"012";i:2;}`},
+	}
+	for _, tt := range data {
+		b, err := Marshal(tt.v)
+		assert.NoError(err)
+		assert.Equal(tt.expected, string(b))
+	}
+	b, err := MarshalWithOptions(numericFields{A: 1}, StructsAsObjects(true))
+	assert.NoError(err)
+	assert.Equal(`O:8:"stdClass":2:{s:2:"12";i:1;s:3:"012";i:0;}`, string(b))
+	var decoded numericFields
+	assert.NoError(Unmarshal([]byte(`a:1:{i:12;i:3;}`), &decoded))
+	assert.Equal(3, decoded.A)
+
+	duplicates := []interface{}{
+		map[float64]int{1.2: 1, 1.7: 2},
+		map[interface{}]int{"1": 1, 1: 2},
+		map[interface{}]int{true: 1, 1: 2},
+		OrderedArray{{Key: 0, Value: 1}, {Key: "0", Value: 2}},
+	}
+	for _, v := range duplicates {
+		_, err := Marshal(v)
+		var valueErr *UnsupportedValueError
+		assert.True(errors.As(err, &valueErr), "%v", v)
+	}
+
+	arr := OrderedArray{{Key: int64(5), Value: "a"}}
+	val, ok := arr.Get("5")
+	assert.True(ok)
+	assert.Equal("a", val)
+}
+
+func TestPhpSerializeBytes(t *testing.T) {
+	assert := assert.New(t)
+	data := []struct {
+		v        interface{}
+		expected string
+	}{
+		{[]byte("ab\x00\xff"), "s:4:\"ab\x00\xff\";"},
+		{[3]byte{'a', 'b', 'c'}, `s:3:"abc";`},
+		{[]byte(nil), `N;`},
+		{json.RawMessage(`{}`), `s:2:"{}";`},
+		{struct {
+			B   []byte  `php:"b"`
+			A   [2]byte `php:"a"`
+			Arr []byte  `php:"arr,array"`
+			Nil []byte  `php:"nil"`
+		}{B: []byte("x"), A: [2]byte{'y', 'z'}, Arr: []byte{1, 2}}, `a:4:{s:1:"b";s:1:"x";s:1:"a";s:2:"yz";s:3:"arr";a:2:{i:0;i:1;i:1;i:2;}s:3:"nil";N;}`},
+	}
+	for _, tt := range data {
+		b, err := Marshal(tt.v)
+		assert.NoError(err)
+		assert.Equal(tt.expected, string(b))
+	}
+}
+
+func TestPhpSerializeNilEmbedded(t *testing.T) {
+	assert := assert.New(t)
+	type Inner struct {
+		A int `php:"a"`
+	}
+	type Outer struct {
+		*Inner
+		B int `php:"b"`
+	}
+	v := Outer{B: 1}
+	b, err := Marshal(v)
+	assert.NoError(err)
+	assert.Equal(`a:1:{s:1:"b";i:1;}`, string(b))
+	b, err = Marshal(&v)
+	assert.NoError(err)
+	assert.Equal(`a:1:{s:1:"b";i:1;}`, string(b))
+	assert.Nil(v.Inner)
+
+	b, err = Marshal(Outer{Inner: &Inner{A: 2}, B: 1})
+	assert.NoError(err)
+	assert.Equal(`a:2:{s:1:"b";i:1;s:1:"a";i:2;}`, string(b))
+}
+
+func TestPhpSerializeCycles(t *testing.T) {
+	assert := assert.New(t)
+	m := map[string]interface{}{}
+	m["self"] = m
+	s := []interface{}{nil}
+	s[0] = s
+	arr := OrderedArray{{Key: 0}}
+	arr[0].Value = arr
+	for _, v := range []interface{}{m, s, arr} {
+		_, err := Marshal(v)
+		var valueErr *UnsupportedValueError
+		if assert.True(errors.As(err, &valueErr)) {
+			assert.Contains(valueErr.Str, "cycle")
+		}
+		// path of cycle would repeat the same element for each level
+		var pathErr *PathError
+		assert.False(errors.As(err, &pathErr))
+	}
+
+	type Node struct {
+		Next *Node `php:"next"`
+	}
+	n := &Node{}
+	n.Next = n
+	b, err := Marshal(n)
+	assert.NoError(err)
+	assert.Equal(`a:1:{s:4:"next";R:1;}`, string(b))
+
+	var deep interface{}
+	for i := 0; i < defaultMaxDepth+1; i++ {
+		deep = []interface{}{deep}
+	}
+	_, err = Marshal(deep)
+	var valueErr *UnsupportedValueError
+	assert.True(errors.As(err, &valueErr))
+	assert.Equal(valueErr, err)
+	_, err = MarshalWithOptions(deep, MaxDepth(-1))
+	assert.NoError(err)
+}
+
+func TestPhpSerializeBigNumbers(t *testing.T) {
+	assert := assert.New(t)
+	huge, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	data := []struct {
+		opts     []EncoderOption
+		v        interface{}
+		expected string
+	}{
+		{nil, big.NewInt(-42), `i:-42;`},
+		{nil, *big.NewInt(42), `i:42;`},
+		{nil, huge, `d:1.2345678901234568E+29;`},
+		{[]EncoderOption{BigNumbers(BigString)}, huge, `s:30:"123456789012345678901234567890";`},
+		{nil, big.NewRat(6, 3), `i:2;`},
+		{nil, big.NewRat(1, 4), `d:0.25;`},
+		{[]EncoderOption{BigNumbers(BigString)}, big.NewRat(1, 3), `s:3:"1/3";`},
+		{nil, big.NewFloat(1.5), `d:1.5;`},
+		{nil, big.NewFloat(2), `i:2;`},
+		{nil, big.NewFloat(-1e18), `i:-1000000000000000000;`},
+		{nil, big.NewFloat(1e19), `d:1.0E+19;`},
+		{[]EncoderOption{BigNumbers(BigString)}, big.NewFloat(1.5), `s:3:"1.5";`},
+		{nil, json.Number("7"), `i:7;`},
+		{nil, json.Number("7.5"), `d:7.5;`},
+		{[]EncoderOption{BigNumbers(BigString)}, json.Number("99999999999999999999"), `s:20:"99999999999999999999";`},
+		{nil, uint64(math.MaxInt64), `i:9223372036854775807;`},
+		{nil, uint64(math.MaxUint64), `d:1.8446744073709552E+19;`},
+		{[]EncoderOption{BigNumbers(BigString)}, uint(math.MaxUint64), `s:20:"18446744073709551615";`},
+		{nil, struct {
+			A big.Int     `php:"a"`
+			B *big.Int    `php:"b"`
+			N json.Number `php:"n"`
+		}{A: *big.NewInt(1), B: big.NewInt(2), N: "3"}, `a:3:{s:1:"a";i:1;s:1:"b";i:2;s:1:"n";i:3;}`},
+	}
+	for _, tt := range data {
+		b, err := MarshalWithOptions(tt.v, tt.opts...)
+		assert.NoError(err)
+		assert.Equal(tt.expected, string(b))
+	}
+
+	_, err := Marshal(json.Number("abc"))
+	var valueErr *UnsupportedValueError
+	assert.True(errors.As(err, &valueErr))
+}
+
+func TestPhpSerializeTime(t *testing.T) {
+	assert := assert.New(t)
+	tm := time.Date(2024, 1, 2, 3, 4, 5, 600000000, time.UTC)
+	data := []struct {
+		opts     []EncoderOption
+		v        interface{}
+		expected string
+	}{
+		{nil, tm, `s:22:"2024-01-02T03:04:05.6Z";`},
+		{[]EncoderOption{TimeFormat(TimeUnix)}, tm, `i:1704164645;`},
+		{[]EncoderOption{TimeLayout("2006-01-02")}, tm, `s:10:"2024-01-02";`},
+		{[]EncoderOption{TimeFormat(TimeDateTime)}, tm, `O:8:"DateTime":3:{s:4:"date";s:26:"2024-01-02 03:04:05.600000";s:13:"timezone_type";i:3;s:8:"timezone";s:3:"UTC";}`},
+		{[]EncoderOption{TimeFormat(TimeDateTimeImmutable)}, tm.In(time.FixedZone("", 2*3600)), `O:17:"DateTimeImmutable":3:{s:4:"date";s:26:"2024-01-02 05:04:05.600000";s:13:"timezone_type";i:1;s:8:"timezone";s:6:"+02:00";}`},
+		{[]EncoderOption{TimeFormat(TimeUnix)}, struct {
+			At  time.Time  `php:"at"`
+			Ptr *time.Time `php:"ptr"`
+		}{At: tm, Ptr: &tm}, `a:2:{s:2:"at";i:1704164645;s:3:"ptr";i:1704164645;}`},
+		{nil, 90 * time.Minute, `i:5400000000000;`},
+		{[]EncoderOption{DurationFormat(DurationSeconds)}, 1500 * time.Millisecond, `d:1.5;`},
+		{[]EncoderOption{DurationFormat(DurationString)}, struct {
+			D time.Duration `php:"d"`
+		}{90 * time.Minute}, `a:1:{s:1:"d";s:7:"1h30m0s";}`},
+	}
+	for _, tt := range data {
+		b, err := MarshalWithOptions(tt.v, tt.opts...)
+		assert.NoError(err)
+		assert.Equal(tt.expected, string(b))
+	}
+}
+
+func TestPhpSerializeTextMarshaler(t *testing.T) {
+	assert := assert.New(t)
+	ip := net.IPv4(10, 0, 0, 1)
+	b, err := Marshal([]interface{}{ip, phpColor{1, 2, 3}, &phpColor{4, 5, 6}})
+	assert.NoError(err)
+	assert.Equal(`a:3:{i:0;s:8:"10.0.0.1";i:1;s:7:"#010203";i:2;s:7:"#040506";}`, string(b))
+
+	b, err = Marshal(map[phpColor]int{{1, 2, 3}: 1})
+	assert.NoError(err)
+	assert.Equal(`a:1:{s:7:"#010203";i:1;}`, string(b))
+
+	b, err = Marshal(map[phpPoint]int{{1, 2}: 1})
+	assert.NoError(err)
+	assert.Equal(`a:1:{s:5:"(1,2)";i:1;}`, string(b))
+
+	b, err = Marshal(struct {
+		Color phpColor `php:"color"`
+		IP    net.IP   `php:"ip"`
+	}{Color: phpColor{0xff, 0, 0}, IP: ip})
+	assert.NoError(err)
+	assert.Equal(`a:2:{s:5:"color";s:7:"#ff0000";s:2:"ip";s:8:"10.0.0.1";}`, string(b))
+}
+
+type phpColor struct {
+	R, G, B uint8
+}
+
+func (c phpColor) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)), nil
+}
+
+func (c *phpColor) UnmarshalText(b []byte) error {
+	_, err := fmt.Sscanf(string(b), "#%02x%02x%02x", &c.R, &c.G, &c.B)
+	return err
+}
+
+type phpPoint struct {
+	X, Y int
+}
+
+func (p phpPoint) String() string {
+	return fmt.Sprintf("(%d,%d)", p.X, p.Y)
+}
+
+// phpID implements both marshaler interfaces, AppendPHP wins
+type phpID int
+
+func (id phpID) AppendPHP(dst []byte) ([]byte, error) {
+	if id < 0 {
+		return nil, errors.New("negative id")
+	}
+	dst = append(dst, "i:"...)
+	dst = strconv.AppendInt(dst, int64(id), 10)
+	return append(dst, ';'), nil
+}
+
+func (id phpID) MarshalPHP() ([]byte, error) {
+	return []byte(`s:2:"id";`), nil
+}
+
+type errMarshaler struct{}
+
+func (errMarshaler) MarshalPHP() ([]byte, error) {
+	return nil, io.ErrShortWrite
+}
+
+func TestPhpSerializeAppend(t *testing.T) {
+	var b []byte
+	b = AppendObjectHeader(b, "Foo", 3)
+	b = AppendString(b, "a")
+	b = AppendArrayHeader(b, 2)
+	b = AppendInt(AppendInt(b, 0), -1)
+	b = AppendInt(b, 1)
+	b = AppendUint(b, math.MaxUint64)
+	b = append(b, '}')
+	b = AppendBytes(b, []byte("b"))
+	b = AppendFloat(AppendBool(b, true), 0.1, 32)
+	b = AppendNil(AppendString(b, "c"))
+	b = append(b, '}')
+	assert.Equal(t, `O:3:"Foo":3:{s:1:"a";a:2:{i:0;i:-1;i:1;d:1.8446744073709552E+19;}s:1:"b";b:1;d:0.1;s:1:"c";N;}`, string(b))
+}
+
+type chunkWriter struct {
+	bytes.Buffer
+	chunks int
+	max    int
+	err    error
+}
+
+func (w *chunkWriter) Write(b []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+	w.chunks++
+	if len(b) > w.max {
+		w.max = len(b)
+	}
+	return w.Buffer.Write(b)
+}
+
+func TestPhpSerializeBufferSize(t *testing.T) {
+	type Row struct {
+		ID   int    `php:"id"`
+		Name string `php:"name,omitempty"`
+	}
+	rows := make([]Row, 1000)
+	for i := range rows {
+		rows[i] = Row{ID: i, Name: strings.Repeat("x", i%3)}
+	}
+	value := map[string]interface{}{
+		"rows": rows,
+		"obj":  PhpObject{Name: "Foo", PhpVars: rows[:100]},
+	}
+	expected, err := MarshalWithOptions(value, SortMapKeys(true))
+	assert.NoError(t, err)
+
+	var w chunkWriter
+	assert.NoError(t, NewEncoder(&w, SortMapKeys(true), BufferSize(256)).Encode(value))
+	assert.Equal(t, string(expected), w.String())
+	assert.True(t, w.chunks > 10)
+	// php object is held until its properties header is written
+	assert.True(t, w.max > 1000)
+
+	w = chunkWriter{}
+	assert.NoError(t, NewEncoder(&w, BufferSize(256)).Encode(rows))
+	expected, err = Marshal(rows)
+	assert.NoError(t, err)
+	assert.Equal(t, string(expected), w.String())
+	assert.True(t, w.max < 512)
+
+	w = chunkWriter{err: errors.New("closed")}
+	assert.EqualError(t, NewEncoder(&w, BufferSize(256)).Encode(rows), "closed")
+}
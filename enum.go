@@ -0,0 +1,150 @@
+package phpserialize
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Enum is php 8.1 enum case serialized as E:<len>:"Class:Case";.
+// Cases of enums which are not registered with RegisterEnum are
+// decoded into Enum when decoding into interface{}
+type Enum struct {
+	Class string
+	Case  string
+}
+
+type enumInfo struct {
+	class  string
+	names  map[interface{}]string
+	values map[string]reflect.Value
+}
+
+var (
+	enumType    = reflect.TypeOf(Enum{})
+	enumTypes   sync.Map
+	enumClasses sync.Map
+)
+
+// RegisterEnum registers go constants as cases of php enum class.
+// Cases maps php case names to go values which must all be of the same
+// comparable type, values of that type are then encoded as enum cases.
+// Enums should be registered before values of their type are encoded
+func RegisterEnum(class string, cases map[string]interface{}) {
+	info := &enumInfo{
+		class:  class,
+		names:  make(map[interface{}]string, len(cases)),
+		values: make(map[string]reflect.Value, len(cases)),
+	}
+	var t reflect.Type
+	for name, c := range cases {
+		v := reflect.ValueOf(c)
+		if t == nil {
+			t = v.Type()
+		} else if v.Type() != t {
+			panic(fmt.Sprintf("phpserialize: cases of enum %s have different types %v and %v", class, t, v.Type()))
+		}
+		info.names[c] = name
+		info.values[name] = v
+	}
+	if t == nil {
+		panic(fmt.Sprintf("phpserialize: enum %s has no cases", class))
+	}
+	enumTypes.Store(t, info)
+	enumClasses.Store(class, info)
+}
+
+// registeredEnum returns enum registered for type t, only
+// named types declared outside of standard library can be enums
+func registeredEnum(t reflect.Type) (*enumInfo, bool) {
+	if t.PkgPath() == "" {
+		return nil, false
+	}
+	info, ok := enumTypes.Load(t)
+	if !ok {
+		return nil, false
+	}
+	return info.(*enumInfo), true
+}
+
+func isEnum(v reflect.Value) bool {
+	return v.Type() == enumType
+}
+
+func (e *Encoder) encodeEnum(v reflect.Value) error {
+	enum := v.Interface().(Enum)
+	e.encodeEnumCase(enum.Class, enum.Case)
+	return nil
+}
+
+func (e *Encoder) encodeRegisteredEnum(v reflect.Value, info *enumInfo) error {
+	name, ok := info.names[v.Interface()]
+	if !ok {
+		return &UnsupportedValueError{Value: v, Str: fmt.Sprintf("%v is not a case of enum %s", v.Interface(), info.class)}
+	}
+	e.encodeEnumCase(info.class, name)
+	return nil
+}
+
+func (e *Encoder) encodeEnumCase(class, name string) {
+	e.WriteString("E:")
+	e.Write(appendInt(e.scratch[:0], int64(len(class)+len(name)+1)))
+	e.WriteString(`:"`)
+	e.WriteString(class)
+	e.WriteByte(':')
+	e.WriteString(name)
+	e.WriteString(`";`)
+}
+
+func (d *Decoder) readEnum() (string, string, error) {
+	if err := d.expectPrefix('E'); err != nil {
+		return "", "", err
+	}
+	l, err := d.readLen(':')
+	if err != nil {
+		return "", "", err
+	}
+	start := d.off
+	b, err := d.readQuoted(l)
+	if err != nil {
+		return "", "", err
+	}
+	i := bytes.IndexByte(b, ':')
+	if i < 0 {
+		d.off = start
+		return "", "", d.syntaxError("enum case")
+	}
+	return string(b[:i]), string(b[i+1:]), d.expect(';')
+}
+
+func (d *Decoder) decodeEnum(v reflect.Value) error {
+	class, name, err := d.readEnum()
+	if err != nil {
+		return err
+	}
+	v = indirect(v, false)
+	if info, ok := registeredEnum(v.Type()); ok {
+		c, ok := info.values[name]
+		if !ok || info.class != class {
+			return d.typeError("enum case "+class+":"+name, v)
+		}
+		v.Set(c)
+		return nil
+	}
+	switch {
+	case isEnum(v):
+		v.Set(reflect.ValueOf(Enum{Class: class, Case: name}))
+	case isEmptyInterface(v):
+		if info, ok := enumClasses.Load(class); ok {
+			if c, ok := info.(*enumInfo).values[name]; ok {
+				v.Set(c)
+				return nil
+			}
+		}
+		v.Set(reflect.ValueOf(Enum{Class: class, Case: name}))
+	default:
+		return d.typeError("enum case "+class+":"+name, v)
+	}
+	return nil
+}
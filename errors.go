@@ -1,5 +1,130 @@
 package phpserialize
 
+import "reflect"
+
+// TypeError is an error of unstructured type mismatch
+//
+// Deprecated: encoder and decoder return UnsupportedTypeError,
+// UnsupportedValueError, MarshalerError, SyntaxError and UnmarshalTypeError
 type TypeError struct {
 	error
 }
+
+// UnsupportedTypeError is returned by Marshal when attempting
+// to encode value of type php serialize can't represent
+type UnsupportedTypeError struct {
+	Type reflect.Type
+}
+
+func (e *UnsupportedTypeError) Error() string {
+	return "phpserialize: unsupported type: " + e.Type.String()
+}
+
+// UnsupportedValueError is returned by Marshal when attempting
+// to encode unsupported value of otherwise supported type
+type UnsupportedValueError struct {
+	Value reflect.Value
+	Str   string
+	// pathless errors are not wrapped in PathError, path of too deep
+	// or cyclic value is as long as its nesting
+	pathless bool
+}
+
+func (e *UnsupportedValueError) Error() string {
+	return "phpserialize: unsupported value: " + e.Str
+}
+
+// MarshalerError is returned when MarshalPHP or SerializePHP
+// method of Type fails
+type MarshalerError struct {
+	Type   reflect.Type
+	Err    error
+	method string
+}
+
+func (e *MarshalerError) Error() string {
+	method := e.method
+	if method == "" {
+		method = "MarshalPHP"
+	}
+	return "phpserialize: error calling " + method + " for type " + e.Type.String() + ": " + e.Err.Error()
+}
+
+func (e *MarshalerError) Unwrap() error {
+	return e.Err
+}
+
+// SyntaxError is a description of malformed php serialized data,
+// Offset is the number of input bytes read before the error
+type SyntaxError struct {
+	msg    string
+	Offset int64
+}
+
+func (e *SyntaxError) Error() string {
+	return e.msg
+}
+
+// UnmarshalTypeError describes serialized value that was not
+// appropriate for a value of specific Go type
+type UnmarshalTypeError struct {
+	Value  string       // description of serialized value like "array"
+	Type   reflect.Type // type of Go value it could not be assigned to
+	Offset int64        // input bytes read before the value
+	Field  string       // full path of struct field holding the value
+}
+
+func (e *UnmarshalTypeError) Error() string {
+	if e.Field != "" {
+		return "phpserialize: cannot unmarshal " + e.Value + " into Go struct field " + e.Field + " of type " + e.Type.String()
+	}
+	return "phpserialize: cannot unmarshal " + e.Value + " into Go value of type " + e.Type.String()
+}
+
+// InvalidUnmarshalError is returned when argument
+// passed to Unmarshal is not a non-nil pointer
+type InvalidUnmarshalError struct {
+	Type reflect.Type
+}
+
+func (e *InvalidUnmarshalError) Error() string {
+	if e.Type == nil {
+		return "phpserialize: Unmarshal(nil)"
+	}
+	if e.Type.Kind() != reflect.Ptr {
+		return "phpserialize: Unmarshal(non-pointer " + e.Type.String() + ")"
+	}
+	return "phpserialize: Unmarshal(nil " + e.Type.String() + ")"
+}
+
+// PathError is returned when encoding of value nested in arrays or
+// objects fails, Path locates the value like users[3].address.zip
+type PathError struct {
+	Path string
+	Err  error
+}
+
+func (e *PathError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *PathError) Unwrap() error {
+	return e.Err
+}
+
+// wrapPath prepends path element to the path of failed nested value
+func wrapPath(err error, elem string) error {
+	if ve, ok := err.(*UnsupportedValueError); ok && ve.pathless {
+		return err
+	}
+	pe, ok := err.(*PathError)
+	if !ok {
+		return &PathError{Path: elem, Err: err}
+	}
+	if pe.Path[0] == '[' {
+		pe.Path = elem + pe.Path
+	} else {
+		pe.Path = elem + "." + pe.Path
+	}
+	return pe
+}
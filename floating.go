@@ -1,6 +1,8 @@
 package phpserialize
 
 import (
+	"bytes"
+	"math"
 	"reflect"
 	"strconv"
 )
@@ -20,12 +22,126 @@ func (e *Encoder) encodeFloating(v reflect.Value) error {
 	if v.Kind() == reflect.Float32 {
 		bitSize = 32
 	}
-	b := appendFloat(e.scratch[:0], v.Float(), bitSize)
+	b := appendFloat(e.scratch[:0], v.Float(), bitSize, e.floatPrecision)
 	e.Write(b)
 	e.WriteByte(';')
 	return nil
 }
 
-func appendFloat(dst []byte, f float64, bitSize int) []byte {
-	return strconv.AppendFloat(dst, f, 'f', -1, bitSize)
+// appendFloat formats f the way php serialize does: with given number of
+// significant digits or shortest representation which reads back to the same
+// value if precision is not positive, exponent notation for large and small
+// magnitudes and INF, -INF or NAN for non-finite values
+func appendFloat(dst []byte, f float64, bitSize, precision int) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(dst, "NAN"...)
+	case math.IsInf(f, 1):
+		return append(dst, "INF"...)
+	case math.IsInf(f, -1):
+		return append(dst, "-INF"...)
+	}
+	var buf [32]byte
+	var b []byte
+	if precision > 0 {
+		b = strconv.AppendFloat(buf[:0], f, 'e', precision-1, bitSize)
+	} else {
+		precision = phpPrecision
+		b = strconv.AppendFloat(buf[:0], f, 'e', -1, bitSize)
+	}
+	if b[0] == '-' {
+		dst = append(dst, '-')
+		b = b[1:]
+	}
+	// split d.ddde±xx into digits and position of decimal point
+	e := bytes.IndexByte(b, 'e')
+	exp, _ := strconv.Atoi(string(b[e+1:]))
+	digits := b[:e]
+	if len(digits) > 1 {
+		digits = append(digits[:1], digits[2:]...)
+	}
+	for len(digits) > 1 && digits[len(digits)-1] == '0' {
+		digits = digits[:len(digits)-1]
+	}
+	return appendDigits(dst, digits, exp+1, precision)
+}
+
+// phpPrecision is number of digits php serialize_precision -1 uses
+// to decide when to switch to exponent notation
+const phpPrecision = 17
+
+// appendDigits writes digits with decimal point at decpt like php_gcvt does
+func appendDigits(dst, digits []byte, decpt, precision int) []byte {
+	switch {
+	case decpt < -3 || decpt > precision:
+		dst = append(dst, digits[0], '.')
+		if len(digits) > 1 {
+			dst = append(dst, digits[1:]...)
+		} else {
+			dst = append(dst, '0')
+		}
+		dst = append(dst, 'E')
+		if decpt--; decpt < 0 {
+			dst = append(dst, '-')
+			decpt = -decpt
+		} else {
+			dst = append(dst, '+')
+		}
+		return strconv.AppendInt(dst, int64(decpt), 10)
+	case decpt < 0:
+		dst = append(dst, "0."...)
+		for i := decpt; i < 0; i++ {
+			dst = append(dst, '0')
+		}
+		return append(dst, digits...)
+	}
+	for i := 0; i < decpt; i++ {
+		if i < len(digits) {
+			dst = append(dst, digits[i])
+		} else {
+			dst = append(dst, '0')
+		}
+	}
+	if decpt < len(digits) {
+		if decpt == 0 {
+			dst = append(dst, '0')
+		}
+		dst = append(dst, '.')
+		dst = append(dst, digits[decpt:]...)
+	}
+	return dst
+}
+
+func (d *Decoder) readFloating() (float64, error) {
+	if err := d.expectPrefix('d'); err != nil {
+		return 0, err
+	}
+	start := d.off
+	b, err := d.readUntil(';')
+	if err != nil {
+		return 0, err
+	}
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		d.off = start
+		return 0, d.syntaxError("float")
+	}
+	return f, nil
+}
+
+func (d *Decoder) decodeFloating(v reflect.Value) error {
+	f, err := d.readFloating()
+	if err != nil {
+		return err
+	}
+	v = indirect(v, false)
+	switch {
+	case isFloating(v):
+		v.SetFloat(f)
+	case isEmptyInterface(v):
+		v.Set(reflect.ValueOf(f))
+	default:
+		return d.typeError("float", v)
+	}
+	return nil
 }
@@ -1,7 +1,8 @@
 package phpserialize
 
 import (
-	"errors"
+	"math"
+	"math/big"
 	"reflect"
 	"strconv"
 )
@@ -20,17 +21,41 @@ func isInteger(v reflect.Value) bool {
 	}
 }
 
+func isSigned(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isUnsigned(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
 func (e *Encoder) encodeInteger(v reflect.Value) error {
-	e.Write(integerPrefix)
 	var b []byte
 	switch v.Kind() {
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		b = appendInt(e.scratch[:0], v.Int())
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		b = appendUint(e.scratch[:0], v.Uint())
+		u := v.Uint()
+		if u > math.MaxInt64 {
+			// php would clamp it to PHP_INT_MAX
+			e.writeBig(float64(u), strconv.FormatUint(u, 10))
+			return nil
+		}
+		b = appendUint(e.scratch[:0], u)
 	default:
-		return TypeError{errors.New("%v is not an integer")}
+		return &UnsupportedTypeError{v.Type()}
 	}
+	e.Write(integerPrefix)
 	b = append(b, ';')
 	e.Write(b)
 	return nil
@@ -43,3 +68,92 @@ func appendInt(dst []byte, i int64) []byte {
 func appendUint(dst []byte, i uint64) []byte {
 	return strconv.AppendUint(dst, i, 10)
 }
+
+// readIntegerText reads digits of integer which may not fit in int64
+func (d *Decoder) readIntegerText() ([]byte, error) {
+	if err := d.expectPrefix('i'); err != nil {
+		return nil, err
+	}
+	start := d.off
+	b, err := d.readUntil(';')
+	if err != nil {
+		return nil, err
+	}
+	digits := b
+	if len(digits) > 0 && (digits[0] == '-' || digits[0] == '+') {
+		digits = digits[1:]
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			d.off = start + i + len(b) - len(digits)
+			return nil, d.syntaxError("digit")
+		}
+	}
+	if len(digits) == 0 {
+		d.off = start + len(b)
+		return nil, d.syntaxError("digit")
+	}
+	return b, nil
+}
+
+func (d *Decoder) readInteger() (int64, error) {
+	start := d.off
+	b, err := d.readIntegerText()
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		d.off = start
+		return 0, d.errorf("integer %s out of range", b)
+	}
+	return i, nil
+}
+
+func (d *Decoder) decodeInteger(v reflect.Value) error {
+	b, err := d.readIntegerText()
+	if err != nil {
+		return err
+	}
+	v = indirect(v, false)
+	i, err := strconv.ParseInt(string(b), 10, 64)
+	if err != nil {
+		// php writes integers up to 64 bits, wider ones come from other encoders
+		switch {
+		case isUnsigned(v):
+			if u, err := strconv.ParseUint(string(b), 10, 64); err == nil && !v.OverflowUint(u) {
+				v.SetUint(u)
+				return nil
+			}
+		case isFloating(v):
+			f, _ := strconv.ParseFloat(string(b), 64)
+			v.SetFloat(f)
+			return nil
+		case isEmptyInterface(v):
+			n, _ := new(big.Int).SetString(string(b), 10)
+			v.Set(reflect.ValueOf(n))
+			return nil
+		}
+		return d.typeError("integer "+string(b), v)
+	}
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if v.OverflowInt(i) {
+			return d.typeError("integer "+strconv.FormatInt(i, 10), v)
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if i < 0 || v.OverflowUint(uint64(i)) {
+			return d.typeError("integer "+strconv.FormatInt(i, 10), v)
+		}
+		v.SetUint(uint64(i))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(float64(i))
+	default:
+		if !isEmptyInterface(v) {
+			return d.typeError("integer", v)
+		}
+		v.Set(reflect.ValueOf(i))
+	}
+	return nil
+}
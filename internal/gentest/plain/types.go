@@ -0,0 +1,50 @@
+// Package plain mirrors types of gentest without generated methods
+// so that they are written by the reflective encoder
+package plain
+
+type User struct {
+	ID       int64    `php:"id"`
+	Name     string   `php:"name"`
+	Email    string   `php:"email,omitempty"`
+	Admin    bool     `php:"admin"`
+	Score    float64  `php:"score"`
+	Ratio    float32  `php:"ratio,omitempty"`
+	Age      uint8    `php:"age"`
+	Quota    uint64   `php:"quota,omitempty"`
+	Token    []byte   `php:"token,protected"`
+	Raw      []byte   `php:"raw,array,omitempty"`
+	Password string   `php:"password,private"`
+	Home     Address  `json:"home"`
+	Work     *Address `json:"work"`
+	Previous []Address
+	Friends  []*User `php:"friends,omitempty"`
+	Tags     Tags    `php:"tags"`
+	Matrix   [][]int `php:"matrix"`
+	Ignored  string  `php:"-"`
+	internal int
+}
+
+func (User) PHPClassName() string {
+	return "App\\User"
+}
+
+type Address struct {
+	Street string `php:"street"`
+	City   string `php:"city,private"`
+	Zip    string
+	Floor  int `php:"7"`
+}
+
+type Tags struct {
+	Names []string `php:"names"`
+	Flags []bool   `php:"flags,omitempty"`
+}
+
+type Point struct {
+	X int `php:"x"`
+	Y int `php:"y,private"`
+}
+
+func (*Point) PHPClassName() string {
+	return "Point"
+}
@@ -0,0 +1,659 @@
+// Code generated by phpserialize-gen. DO NOT EDIT.
+
+package gentest
+
+import "github.com/dennor/phpserialize"
+
+// AppendPHP appends php serialized x to dst
+func (x User) AppendPHP(dst []byte) ([]byte, error) {
+	var ptrs phpserialize.Pointers
+	return x.appendPHP(dst, &ptrs)
+}
+
+func (x User) appendPHP(dst []byte, ptrs *phpserialize.Pointers) ([]byte, error) {
+	var err error
+	n := 17
+	if x.Email == "" {
+		n--
+	}
+	if x.Ratio == 0 {
+		n--
+	}
+	if x.Quota == 0 {
+		n--
+	}
+	if len(x.Raw) == 0 {
+		n--
+	}
+	if len(x.Friends) == 0 {
+		n--
+	}
+	dst = phpserialize.AppendObjectHeader(dst, x.PHPClassName(), n)
+	dst = append(dst, "s:2:\"id\";"...)
+	dst = phpserialize.AppendInt(dst, x.ID)
+	dst = append(dst, "s:4:\"name\";"...)
+	dst = phpserialize.AppendString(dst, x.Name)
+	if x.Email != "" {
+		dst = append(dst, "s:5:\"email\";"...)
+		dst = phpserialize.AppendString(dst, x.Email)
+	}
+	dst = append(dst, "s:5:\"admin\";"...)
+	dst = phpserialize.AppendBool(dst, x.Admin)
+	dst = append(dst, "s:5:\"score\";"...)
+	dst = phpserialize.AppendFloat(dst, x.Score, 64)
+	if x.Ratio != 0 {
+		dst = append(dst, "s:5:\"ratio\";"...)
+		dst = phpserialize.AppendFloat(dst, float64(x.Ratio), 32)
+	}
+	dst = append(dst, "s:3:\"age\";"...)
+	dst = phpserialize.AppendUint(dst, uint64(x.Age))
+	if x.Quota != 0 {
+		dst = append(dst, "s:5:\"quota\";"...)
+		dst = phpserialize.AppendUint(dst, x.Quota)
+	}
+	dst = append(dst, "s:8:\"\x00*\x00token\";"...)
+	if x.Token == nil {
+		dst = phpserialize.AppendNil(dst)
+	} else {
+		dst = phpserialize.AppendBytes(dst, x.Token)
+	}
+	if len(x.Raw) != 0 {
+		dst = append(dst, "s:3:\"raw\";"...)
+		dst = phpserialize.AppendArrayHeader(dst, len(x.Raw))
+		for i0, v0 := range x.Raw {
+			dst = phpserialize.AppendInt(dst, int64(i0))
+			dst = phpserialize.AppendUint(dst, uint64(v0))
+		}
+		dst = append(dst, '}')
+	}
+	dst = phpserialize.AppendString(dst, "\x00"+new(User).PHPClassName()+"\x00password")
+	dst = phpserialize.AppendString(dst, x.Password)
+	dst = append(dst, "s:4:\"home\";"...)
+	if dst, err = x.Home.appendPHP(dst, ptrs); err != nil {
+		return nil, err
+	}
+	dst = append(dst, "s:4:\"work\";"...)
+	if x.Work == nil {
+		dst = phpserialize.AppendNil(dst)
+	} else if err = ptrs.Add(x.Work); err != nil {
+		return nil, err
+	} else if dst, err = x.Work.appendPHP(dst, ptrs); err != nil {
+		return nil, err
+	}
+	dst = append(dst, "s:8:\"Previous\";"...)
+	dst = phpserialize.AppendArrayHeader(dst, len(x.Previous))
+	for i0, v0 := range x.Previous {
+		dst = phpserialize.AppendInt(dst, int64(i0))
+		if dst, err = v0.appendPHP(dst, ptrs); err != nil {
+			return nil, err
+		}
+	}
+	dst = append(dst, '}')
+	if len(x.Friends) != 0 {
+		dst = append(dst, "s:7:\"friends\";"...)
+		dst = phpserialize.AppendArrayHeader(dst, len(x.Friends))
+		for i0, v0 := range x.Friends {
+			dst = phpserialize.AppendInt(dst, int64(i0))
+			if v0 == nil {
+				dst = phpserialize.AppendNil(dst)
+			} else if err = ptrs.Add(v0); err != nil {
+				return nil, err
+			} else if dst, err = v0.appendPHP(dst, ptrs); err != nil {
+				return nil, err
+			}
+		}
+		dst = append(dst, '}')
+	}
+	dst = append(dst, "s:4:\"tags\";"...)
+	if dst, err = x.Tags.appendPHP(dst, ptrs); err != nil {
+		return nil, err
+	}
+	dst = append(dst, "s:6:\"matrix\";"...)
+	dst = phpserialize.AppendArrayHeader(dst, len(x.Matrix))
+	for i0, v0 := range x.Matrix {
+		dst = phpserialize.AppendInt(dst, int64(i0))
+		if v0 == nil {
+			dst = phpserialize.AppendNil(dst)
+		} else {
+			dst = phpserialize.AppendArrayHeader(dst, len(v0))
+			for i1, v1 := range v0 {
+				dst = phpserialize.AppendInt(dst, int64(i1))
+				dst = phpserialize.AppendInt(dst, int64(v1))
+			}
+			dst = append(dst, '}')
+		}
+	}
+	dst = append(dst, '}')
+	return append(dst, '}'), nil
+}
+
+// MarshalPHP returns php serialized x
+func (x User) MarshalPHP() ([]byte, error) {
+	return x.AppendPHP(nil)
+}
+
+var phpKeysUser = []string{"id", "name", "email", "admin", "score", "ratio", "age", "quota", "\x00*\x00token", "raw", "\x00" + new(User).PHPClassName() + "\x00password", "home", "work", "Previous", "friends", "tags", "matrix"}
+
+var phpNamesUser = []string{"id", "name", "email", "admin", "score", "ratio", "age", "quota", "token", "raw", "password", "home", "work", "Previous", "friends", "tags", "matrix"}
+
+// UnmarshalPHP decodes php serialized data into x
+func (x *User) UnmarshalPHP(data []byte) error {
+	p := phpserialize.NewParser(data)
+	if err := x.unmarshalPHP(p); err != nil {
+		return err
+	}
+	return p.Finish()
+}
+
+func (x *User) unmarshalPHP(p *phpserialize.Parser) error {
+	n, err := p.ReadHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		key, err := p.ReadKey()
+		if err != nil {
+			return err
+		}
+		switch phpserialize.MatchField(key, phpKeysUser, phpNamesUser) {
+		case 0:
+			if !p.Nil() {
+				v0, err := p.ReadInt(64)
+				if err != nil {
+					return err
+				}
+				x.ID = v0
+			}
+		case 1:
+			if !p.Nil() {
+				v0, err := p.ReadString()
+				if err != nil {
+					return err
+				}
+				x.Name = v0
+			}
+		case 2:
+			if !p.Nil() {
+				v0, err := p.ReadString()
+				if err != nil {
+					return err
+				}
+				x.Email = v0
+			}
+		case 3:
+			if !p.Nil() {
+				v0, err := p.ReadBool()
+				if err != nil {
+					return err
+				}
+				x.Admin = v0
+			}
+		case 4:
+			if !p.Nil() {
+				v0, err := p.ReadFloat(64)
+				if err != nil {
+					return err
+				}
+				x.Score = v0
+			}
+		case 5:
+			if !p.Nil() {
+				v0, err := p.ReadFloat(32)
+				if err != nil {
+					return err
+				}
+				x.Ratio = float32(v0)
+			}
+		case 6:
+			if !p.Nil() {
+				v0, err := p.ReadUint(8)
+				if err != nil {
+					return err
+				}
+				x.Age = uint8(v0)
+			}
+		case 7:
+			if !p.Nil() {
+				v0, err := p.ReadUint(64)
+				if err != nil {
+					return err
+				}
+				x.Quota = v0
+			}
+		case 8:
+			if p.Nil() {
+				x.Token = nil
+			} else {
+				v0, err := p.ReadBytes()
+				if err != nil {
+					return err
+				}
+				x.Token = v0
+			}
+		case 9:
+			if p.Nil() {
+				x.Raw = nil
+			} else {
+				v0, err := p.ReadBytes()
+				if err != nil {
+					return err
+				}
+				x.Raw = v0
+			}
+		case 10:
+			if !p.Nil() {
+				v0, err := p.ReadString()
+				if err != nil {
+					return err
+				}
+				x.Password = v0
+			}
+		case 11:
+			if !p.Nil() {
+				if err := x.Home.unmarshalPHP(p); err != nil {
+					return err
+				}
+			}
+		case 12:
+			if p.Nil() {
+				x.Work = nil
+			} else {
+				if x.Work == nil {
+					x.Work = new(Address)
+				}
+				if err := x.Work.unmarshalPHP(p); err != nil {
+					return err
+				}
+			}
+		case 13:
+			if p.Nil() {
+				x.Previous = nil
+			} else {
+				n0, err := p.ReadHeader()
+				if err != nil {
+					return err
+				}
+				x.Previous = make([]Address, n0)
+				for j := 0; j < n0; j++ {
+					i0, err := p.ReadIndex(n0)
+					if err != nil {
+						return err
+					}
+					if !p.Nil() {
+						if err := x.Previous[i0].unmarshalPHP(p); err != nil {
+							return err
+						}
+					}
+				}
+				if err := p.ReadEnd(); err != nil {
+					return err
+				}
+			}
+		case 14:
+			if p.Nil() {
+				x.Friends = nil
+			} else {
+				n0, err := p.ReadHeader()
+				if err != nil {
+					return err
+				}
+				x.Friends = make([]*User, n0)
+				for j := 0; j < n0; j++ {
+					i0, err := p.ReadIndex(n0)
+					if err != nil {
+						return err
+					}
+					if p.Nil() {
+						x.Friends[i0] = nil
+					} else {
+						if x.Friends[i0] == nil {
+							x.Friends[i0] = new(User)
+						}
+						if err := x.Friends[i0].unmarshalPHP(p); err != nil {
+							return err
+						}
+					}
+				}
+				if err := p.ReadEnd(); err != nil {
+					return err
+				}
+			}
+		case 15:
+			if !p.Nil() {
+				if err := x.Tags.unmarshalPHP(p); err != nil {
+					return err
+				}
+			}
+		case 16:
+			if p.Nil() {
+				x.Matrix = nil
+			} else {
+				n0, err := p.ReadHeader()
+				if err != nil {
+					return err
+				}
+				x.Matrix = make([][]int, n0)
+				for j := 0; j < n0; j++ {
+					i0, err := p.ReadIndex(n0)
+					if err != nil {
+						return err
+					}
+					if p.Nil() {
+						x.Matrix[i0] = nil
+					} else {
+						n1, err := p.ReadHeader()
+						if err != nil {
+							return err
+						}
+						x.Matrix[i0] = make([]int, n1)
+						for j := 0; j < n1; j++ {
+							i1, err := p.ReadIndex(n1)
+							if err != nil {
+								return err
+							}
+							if !p.Nil() {
+								v2, err := p.ReadInt(0)
+								if err != nil {
+									return err
+								}
+								x.Matrix[i0][i1] = int(v2)
+							}
+						}
+						if err := p.ReadEnd(); err != nil {
+							return err
+						}
+					}
+				}
+				if err := p.ReadEnd(); err != nil {
+					return err
+				}
+			}
+		default:
+			if err = p.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return p.ReadEnd()
+}
+
+// AppendPHP appends php serialized x to dst
+func (x Address) AppendPHP(dst []byte) ([]byte, error) {
+	var ptrs phpserialize.Pointers
+	return x.appendPHP(dst, &ptrs)
+}
+
+func (x Address) appendPHP(dst []byte, ptrs *phpserialize.Pointers) ([]byte, error) {
+	n := 4
+	dst = phpserialize.AppendArrayHeader(dst, n)
+	dst = append(dst, "s:6:\"street\";"...)
+	dst = phpserialize.AppendString(dst, x.Street)
+	dst = append(dst, "s:4:\"city\";"...)
+	dst = phpserialize.AppendString(dst, x.City)
+	dst = append(dst, "s:3:\"Zip\";"...)
+	dst = phpserialize.AppendString(dst, x.Zip)
+	dst = append(dst, "i:7;"...)
+	dst = phpserialize.AppendInt(dst, int64(x.Floor))
+	return append(dst, '}'), nil
+}
+
+// MarshalPHP returns php serialized x
+func (x Address) MarshalPHP() ([]byte, error) {
+	return x.AppendPHP(nil)
+}
+
+var phpKeysAddress = []string{"street", "\x00stdClass\x00city", "Zip", "7"}
+
+var phpNamesAddress = []string{"street", "city", "Zip", "7"}
+
+// UnmarshalPHP decodes php serialized data into x
+func (x *Address) UnmarshalPHP(data []byte) error {
+	p := phpserialize.NewParser(data)
+	if err := x.unmarshalPHP(p); err != nil {
+		return err
+	}
+	return p.Finish()
+}
+
+func (x *Address) unmarshalPHP(p *phpserialize.Parser) error {
+	n, err := p.ReadHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		key, err := p.ReadKey()
+		if err != nil {
+			return err
+		}
+		switch phpserialize.MatchField(key, phpKeysAddress, phpNamesAddress) {
+		case 0:
+			if !p.Nil() {
+				v0, err := p.ReadString()
+				if err != nil {
+					return err
+				}
+				x.Street = v0
+			}
+		case 1:
+			if !p.Nil() {
+				v0, err := p.ReadString()
+				if err != nil {
+					return err
+				}
+				x.City = v0
+			}
+		case 2:
+			if !p.Nil() {
+				v0, err := p.ReadString()
+				if err != nil {
+					return err
+				}
+				x.Zip = v0
+			}
+		case 3:
+			if !p.Nil() {
+				v0, err := p.ReadInt(0)
+				if err != nil {
+					return err
+				}
+				x.Floor = int(v0)
+			}
+		default:
+			if err = p.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return p.ReadEnd()
+}
+
+// AppendPHP appends php serialized x to dst
+func (x Tags) AppendPHP(dst []byte) ([]byte, error) {
+	var ptrs phpserialize.Pointers
+	return x.appendPHP(dst, &ptrs)
+}
+
+func (x Tags) appendPHP(dst []byte, ptrs *phpserialize.Pointers) ([]byte, error) {
+	n := 2
+	if len(x.Flags) == 0 {
+		n--
+	}
+	dst = phpserialize.AppendArrayHeader(dst, n)
+	dst = append(dst, "s:5:\"names\";"...)
+	dst = phpserialize.AppendArrayHeader(dst, len(x.Names))
+	for i0, v0 := range x.Names {
+		dst = phpserialize.AppendInt(dst, int64(i0))
+		dst = phpserialize.AppendString(dst, v0)
+	}
+	dst = append(dst, '}')
+	if len(x.Flags) != 0 {
+		dst = append(dst, "s:5:\"flags\";"...)
+		dst = phpserialize.AppendArrayHeader(dst, len(x.Flags))
+		for i0, v0 := range x.Flags {
+			dst = phpserialize.AppendInt(dst, int64(i0))
+			dst = phpserialize.AppendBool(dst, v0)
+		}
+		dst = append(dst, '}')
+	}
+	return append(dst, '}'), nil
+}
+
+// MarshalPHP returns php serialized x
+func (x Tags) MarshalPHP() ([]byte, error) {
+	return x.AppendPHP(nil)
+}
+
+var phpKeysTags = []string{"names", "flags"}
+
+var phpNamesTags = []string{"names", "flags"}
+
+// UnmarshalPHP decodes php serialized data into x
+func (x *Tags) UnmarshalPHP(data []byte) error {
+	p := phpserialize.NewParser(data)
+	if err := x.unmarshalPHP(p); err != nil {
+		return err
+	}
+	return p.Finish()
+}
+
+func (x *Tags) unmarshalPHP(p *phpserialize.Parser) error {
+	n, err := p.ReadHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		key, err := p.ReadKey()
+		if err != nil {
+			return err
+		}
+		switch phpserialize.MatchField(key, phpKeysTags, phpNamesTags) {
+		case 0:
+			if p.Nil() {
+				x.Names = nil
+			} else {
+				n0, err := p.ReadHeader()
+				if err != nil {
+					return err
+				}
+				x.Names = make([]string, n0)
+				for j := 0; j < n0; j++ {
+					i0, err := p.ReadIndex(n0)
+					if err != nil {
+						return err
+					}
+					if !p.Nil() {
+						v1, err := p.ReadString()
+						if err != nil {
+							return err
+						}
+						x.Names[i0] = v1
+					}
+				}
+				if err := p.ReadEnd(); err != nil {
+					return err
+				}
+			}
+		case 1:
+			if p.Nil() {
+				x.Flags = nil
+			} else {
+				n0, err := p.ReadHeader()
+				if err != nil {
+					return err
+				}
+				x.Flags = make([]bool, n0)
+				for j := 0; j < n0; j++ {
+					i0, err := p.ReadIndex(n0)
+					if err != nil {
+						return err
+					}
+					if !p.Nil() {
+						v1, err := p.ReadBool()
+						if err != nil {
+							return err
+						}
+						x.Flags[i0] = v1
+					}
+				}
+				if err := p.ReadEnd(); err != nil {
+					return err
+				}
+			}
+		default:
+			if err = p.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return p.ReadEnd()
+}
+
+// AppendPHP appends php serialized x to dst
+func (x Point) AppendPHP(dst []byte) ([]byte, error) {
+	var ptrs phpserialize.Pointers
+	return x.appendPHP(dst, &ptrs)
+}
+
+func (x Point) appendPHP(dst []byte, ptrs *phpserialize.Pointers) ([]byte, error) {
+	n := 2
+	dst = phpserialize.AppendObjectHeader(dst, x.PHPClassName(), n)
+	dst = append(dst, "s:1:\"x\";"...)
+	dst = phpserialize.AppendInt(dst, int64(x.X))
+	dst = phpserialize.AppendString(dst, "\x00"+new(Point).PHPClassName()+"\x00y")
+	dst = phpserialize.AppendInt(dst, int64(x.Y))
+	return append(dst, '}'), nil
+}
+
+// MarshalPHP returns php serialized x
+func (x Point) MarshalPHP() ([]byte, error) {
+	return x.AppendPHP(nil)
+}
+
+var phpKeysPoint = []string{"x", "\x00" + new(Point).PHPClassName() + "\x00y"}
+
+var phpNamesPoint = []string{"x", "y"}
+
+// UnmarshalPHP decodes php serialized data into x
+func (x *Point) UnmarshalPHP(data []byte) error {
+	p := phpserialize.NewParser(data)
+	if err := x.unmarshalPHP(p); err != nil {
+		return err
+	}
+	return p.Finish()
+}
+
+func (x *Point) unmarshalPHP(p *phpserialize.Parser) error {
+	n, err := p.ReadHeader()
+	if err != nil {
+		return err
+	}
+	for i := 0; i < n; i++ {
+		key, err := p.ReadKey()
+		if err != nil {
+			return err
+		}
+		switch phpserialize.MatchField(key, phpKeysPoint, phpNamesPoint) {
+		case 0:
+			if !p.Nil() {
+				v0, err := p.ReadInt(0)
+				if err != nil {
+					return err
+				}
+				x.X = int(v0)
+			}
+		case 1:
+			if !p.Nil() {
+				v0, err := p.ReadInt(0)
+				if err != nil {
+					return err
+				}
+				x.Y = int(v0)
+			}
+		default:
+			if err = p.Skip(); err != nil {
+				return err
+			}
+		}
+	}
+	return p.ReadEnd()
+}
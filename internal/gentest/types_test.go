@@ -0,0 +1,246 @@
+package gentest
+
+import (
+	"math"
+	"testing"
+
+	"github.com/dennor/phpserialize"
+	"github.com/dennor/phpserialize/internal/gentest/plain"
+	"github.com/stretchr/testify/assert"
+)
+
+// mirror copies values into types of package plain which are written
+// by the reflective encoder, pointers shared in values stay shared
+type mirror struct {
+	users     map[*User]*plain.User
+	addresses map[*Address]*plain.Address
+}
+
+func newMirror() *mirror {
+	return &mirror{users: map[*User]*plain.User{}, addresses: map[*Address]*plain.Address{}}
+}
+
+func (m *mirror) user(u User) plain.User {
+	p := plain.User{
+		ID:       u.ID,
+		Name:     u.Name,
+		Email:    u.Email,
+		Admin:    u.Admin,
+		Score:    u.Score,
+		Ratio:    u.Ratio,
+		Age:      u.Age,
+		Quota:    u.Quota,
+		Token:    u.Token,
+		Raw:      u.Raw,
+		Password: u.Password,
+		Home:     m.address(u.Home),
+		Work:     m.addressPtr(u.Work),
+		Tags:     plain.Tags{Names: u.Tags.Names, Flags: u.Tags.Flags},
+		Matrix:   u.Matrix,
+		Ignored:  u.Ignored,
+	}
+	if u.Previous != nil {
+		p.Previous = []plain.Address{}
+		for _, a := range u.Previous {
+			p.Previous = append(p.Previous, m.address(a))
+		}
+	}
+	if u.Friends != nil {
+		p.Friends = []*plain.User{}
+		for _, f := range u.Friends {
+			p.Friends = append(p.Friends, m.userPtr(f))
+		}
+	}
+	return p
+}
+
+func (m *mirror) userPtr(u *User) *plain.User {
+	if u == nil {
+		return nil
+	}
+	if p, ok := m.users[u]; ok {
+		return p
+	}
+	p := new(plain.User)
+	m.users[u] = p
+	*p = m.user(*u)
+	return p
+}
+
+func (m *mirror) address(a Address) plain.Address {
+	return plain.Address{Street: a.Street, City: a.City, Zip: a.Zip, Floor: a.Floor}
+}
+
+func (m *mirror) addressPtr(a *Address) *plain.Address {
+	if a == nil {
+		return nil
+	}
+	if p, ok := m.addresses[a]; ok {
+		return p
+	}
+	p := new(plain.Address)
+	m.addresses[a] = p
+	*p = m.address(*a)
+	return p
+}
+
+func TestGeneratedMatchesReflective(t *testing.T) {
+	cases := []User{
+		{},
+		{
+			ID:       -7,
+			Name:     "ąžuolas",
+			Email:    "a@b.c",
+			Admin:    true,
+			Score:    0.1,
+			Ratio:    1.5,
+			Age:      200,
+			Quota:    42,
+			Token:    []byte{0, 1, 2},
+			Raw:      []byte{3, 4},
+			Password: "secret",
+			Home:     Address{Street: "Old", Zip: "LV-1010", Floor: 3},
+			Work:     &Address{Street: "Main", City: "Riga"},
+			Previous: []Address{{City: "Tartu"}},
+			Friends:  []*User{{Name: "b", Work: &Address{}}, nil},
+			Tags:     Tags{Names: []string{"x", "y"}, Flags: []bool{true}},
+			Matrix:   [][]int{{1, 2}, nil, {}},
+			Ignored:  "ignored",
+			internal: 1,
+		},
+		{Quota: math.MaxUint64},
+	}
+	for _, u := range cases {
+		generated, err := phpserialize.Marshal(&u)
+		if !assert.NoError(t, err) {
+			continue
+		}
+		p := newMirror().userPtr(&u)
+		reflective, err := phpserialize.Marshal(p)
+		assert.NoError(t, err)
+		assert.Equal(t, string(reflective), string(generated))
+
+		if u.Quota > math.MaxInt64 {
+			// written as float which is not decoded into integer
+			continue
+		}
+		var decoded User
+		assert.NoError(t, phpserialize.Unmarshal(generated, &decoded))
+		again, err := decoded.MarshalPHP()
+		assert.NoError(t, err)
+		assert.Equal(t, string(generated), string(again))
+	}
+}
+
+func TestGeneratedSharedPointers(t *testing.T) {
+	work := &Address{Street: "Main"}
+	u := &User{Work: work, Friends: []*User{{Work: work}}}
+	_, err := phpserialize.Marshal(u)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "*gentest.Address shared within value")
+	}
+	// generated code refuses what reflective encoder writes as reference
+	reflective, err := phpserialize.Marshal(newMirror().userPtr(u))
+	assert.NoError(t, err)
+	assert.Contains(t, string(reflective), "R:")
+
+	u = &User{}
+	u.Friends = []*User{u}
+	_, err = u.MarshalPHP()
+	assert.Error(t, err)
+
+	// shared pointers to generated types are references in reflective encoding
+	generated, err := phpserialize.Marshal(struct{ A, B *Address }{work, work})
+	assert.NoError(t, err)
+	m := newMirror()
+	reflective, err = phpserialize.Marshal(struct{ A, B *plain.Address }{m.addressPtr(work), m.addressPtr(work)})
+	assert.NoError(t, err)
+	assert.Equal(t, string(reflective), string(generated))
+	assert.Contains(t, string(generated), "R:")
+}
+
+func TestGeneratedUnmarshalMatchesReflective(t *testing.T) {
+	u := User{
+		ID:       3,
+		Name:     "a",
+		Token:    []byte("t"),
+		Password: "p",
+		Work:     &Address{City: "Riga", Floor: 2},
+		Previous: []Address{{Zip: "z"}},
+		Friends:  []*User{{Name: "b"}, nil},
+		Tags:     Tags{Names: []string{"x"}, Flags: []bool{true}},
+		Matrix:   [][]int{{1}, nil},
+	}
+	reflective, err := phpserialize.Marshal(newMirror().userPtr(&u))
+	if !assert.NoError(t, err) {
+		return
+	}
+	data := []string{
+		string(reflective),
+		// keys are matched ignoring visibility and case, unknown ones are skipped
+		`a:5:{s:2:"ID";i:5;s:4:"NAME";N;s:15:"` + "\x00Other\x00" + `password";s:1:"p";s:7:"unknown";a:1:{i:0;r:1;}s:5:"score";i:3;}`,
+		// objects are read as arrays and the other way round
+		`O:8:"stdClass":2:{s:4:"home";a:2:{s:1:"7";i:4;s:3:"Zip";s:1:"z";}s:7:"friends";O:8:"stdClass":1:{i:0;a:1:{s:4:"name";s:1:"c";}}}`,
+		// elements are placed at their keys
+		`a:2:{s:6:"matrix";a:2:{i:1;a:1:{i:0;i:1;}i:0;N;}s:5:"token";a:2:{i:0;i:1;i:1;i:255;}}`,
+		`a:3:{s:3:"age";i:-0;s:5:"quota";i:18446744073709551615;s:5:"ratio";d:0.5;}`,
+	}
+	for _, tt := range data {
+		var generated User
+		if !assert.NoError(t, phpserialize.Unmarshal([]byte(tt), &generated), tt) {
+			continue
+		}
+		var p plain.User
+		assert.NoError(t, phpserialize.Unmarshal([]byte(tt), &p), tt)
+		assert.Equal(t, p, newMirror().user(generated), tt)
+	}
+
+	invalid := []string{
+		`a:1:{s:3:"age";i:256;}`,
+		`a:1:{s:3:"age";i:-1;}`,
+		`a:1:{s:4:"name";i:1;}`,
+		`a:1:{s:6:"matrix";a:1:{i:1;a:0:{}}}`,
+		`a:1:{s:4:"work";s:0:"";}`,
+		`b:1;`,
+	}
+	for _, tt := range invalid {
+		var generated User
+		assert.Error(t, phpserialize.Unmarshal([]byte(tt), &generated), tt)
+		var p plain.User
+		assert.Error(t, phpserialize.Unmarshal([]byte(tt), &p), tt)
+	}
+}
+
+func TestGeneratedUnmarshalReferences(t *testing.T) {
+	// references point to values outside of data passed to UnmarshalPHP
+	var u User
+	err := phpserialize.Unmarshal([]byte(`a:2:{s:4:"home";a:0:{}s:4:"work";r:2;}`), &u)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "reference 2 can not be resolved")
+	}
+
+	// references to values of generated types are resolved by Unmarshal
+	var v struct{ A, B *Address }
+	assert.NoError(t, phpserialize.Unmarshal([]byte(`a:2:{s:1:"A";a:1:{s:3:"Zip";s:1:"z";}s:1:"B";R:2;}`), &v))
+	if assert.NotNil(t, v.A) {
+		assert.Equal(t, "z", v.A.Zip)
+		assert.Equal(t, v.A, v.B)
+	}
+
+	var a Address
+	assert.Error(t, a.UnmarshalPHP([]byte(`a:0:{}i:1;`)))
+}
+
+func TestGeneratedPointerReceiverClassName(t *testing.T) {
+	// values which are not addressable are objects in both encodings
+	generated, err := phpserialize.Marshal(Point{X: 1, Y: 2})
+	assert.NoError(t, err)
+	reflective, err := phpserialize.Marshal(plain.Point{X: 1, Y: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, string(reflective), string(generated))
+	assert.Equal(t, "O:5:\"Point\":2:{s:1:\"x\";i:1;s:8:\"\x00Point\x00y\";i:2;}", string(generated))
+
+	var p Point
+	assert.NoError(t, phpserialize.Unmarshal(generated, &p))
+	assert.Equal(t, Point{X: 1, Y: 2}, p)
+}
@@ -1,7 +1,9 @@
 package phpserialize
 
 import (
+	"fmt"
 	"reflect"
+	"strconv"
 )
 
 func isIterable(v reflect.Value) bool {
@@ -14,6 +16,10 @@ func isIterable(v reflect.Value) bool {
 }
 
 func (e *Encoder) encodeIterable(v reflect.Value) error {
+	if err := e.enter(v); err != nil {
+		return err
+	}
+	defer e.leave(v)
 	l := v.Len()
 	e.encodePropsHeader(l)
 	for i := 0; i < v.Len(); i++ {
@@ -21,8 +27,42 @@ func (e *Encoder) encodeIterable(v reflect.Value) error {
 		last := len(appendInt(e.scratch[:len(integerPrefix)], int64(i)))
 		e.scratch[last] = ';'
 		e.Write(e.scratch[:last+1])
-		e.encodeValue(v.Index(i))
+		e.state.n++
+		if err := e.encodeValue(v.Index(i)); err != nil {
+			return wrapPath(err, "["+strconv.Itoa(i)+"]")
+		}
+		if err := e.flush(); err != nil {
+			return err
+		}
+	}
+	return e.encodePropsFinish()
+}
+
+func (d *Decoder) decodeIterable(v reflect.Value, n int) error {
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), n, n))
+	}
+	for i := 0; i < n; i++ {
+		key, err := d.decodeKey()
+		if err != nil {
+			return err
+		}
+		// elements are placed at their keys which have to be 0..n-1
+		k, ok := key.(int64)
+		if !ok || k < 0 || k >= int64(n) {
+			return d.typeError(fmt.Sprintf("array key %#v", key), v)
+		}
+		if k < int64(v.Len()) {
+			err = d.decodeValue(v.Index(int(k)))
+		} else {
+			err = d.skip()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i := n; i < v.Len(); i++ {
+		v.Index(i).Set(reflect.Zero(v.Type().Elem()))
 	}
-	e.encodePropsFinish()
 	return nil
 }
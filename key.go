@@ -0,0 +1,142 @@
+package phpserialize
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// phpKey is a key of php array after coercion php applies to keys,
+// either an integer or a string
+type phpKey struct {
+	s   string
+	i   int64
+	str bool
+}
+
+func (k phpKey) value() interface{} {
+	if k.str {
+		return k.s
+	}
+	return k.i
+}
+
+func (k phpKey) String() string {
+	if k.str {
+		return k.s
+	}
+	return strconv.FormatInt(k.i, 10)
+}
+
+// less orders integer keys numerically before string keys
+func (k phpKey) less(o phpKey) bool {
+	switch {
+	case k.str != o.str:
+		return !k.str
+	case k.str:
+		return k.s < o.s
+	}
+	return k.i < o.i
+}
+
+// toPhpKey coerces v the way php does with array keys: strings holding
+// canonical decimal integers, bools and floats become integers, floats
+// are truncated and nil becomes empty string. Numeric strings are left
+// alone when numeric is false as php does for object properties
+func toPhpKey(v reflect.Value, numeric bool) (phpKey, error) {
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	switch {
+	case isNil(v):
+		return phpKey{str: true}, nil
+	case isString(v):
+		return stringKey(v.String(), numeric), nil
+	}
+	if m, ok := textMarshaler(v); ok {
+		b, err := m.MarshalText()
+		if err != nil {
+			return phpKey{}, &MarshalerError{Type: v.Type(), Err: err, method: "MarshalText"}
+		}
+		return stringKey(string(b), numeric), nil
+	}
+	switch {
+	case isBool(v):
+		if v.Bool() {
+			return phpKey{i: 1}, nil
+		}
+		return phpKey{}, nil
+	case isSigned(v):
+		return phpKey{i: v.Int()}, nil
+	case isUnsigned(v):
+		if u := v.Uint(); u > math.MaxInt64 {
+			return phpKey{s: strconv.FormatUint(u, 10), str: true}, nil
+		}
+		return phpKey{i: int64(v.Uint())}, nil
+	case isFloating(v):
+		// php uses 0 for floats out of integer range
+		f := math.Trunc(v.Float())
+		if f >= math.MinInt64 && f < -math.MinInt64 {
+			return phpKey{i: int64(f)}, nil
+		}
+		return phpKey{}, nil
+	case v.Type().Implements(fmtStringerType):
+		return stringKey(v.Interface().(fmt.Stringer).String(), numeric), nil
+	}
+	return phpKey{}, &UnsupportedTypeError{v.Type()}
+}
+
+// stringKey converts s to integer key when it is a canonical decimal
+// integer like "12" or "-3", but not "012", "+1" or "-0"
+func stringKey(s string, numeric bool) phpKey {
+	if !numeric || s == "" || len(s) > 20 {
+		return phpKey{s: s, str: true}
+	}
+	digits := s
+	if s[0] == '-' {
+		digits = s[1:]
+	}
+	if digits == "" || (digits[0] == '0' && len(s) > 1) {
+		return phpKey{s: s, str: true}
+	}
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return phpKey{s: s, str: true}
+		}
+	}
+	i, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return phpKey{s: s, str: true}
+	}
+	return phpKey{i: i}
+}
+
+// uniqueKeys reports whether distinct go map keys of type t
+// are guaranteed to stay distinct after coercion
+func uniqueKeys(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String:
+		return true
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return !isTextMarshalerType(t)
+	}
+	return false
+}
+
+func (e *Encoder) writeKey(k phpKey) {
+	if k.str {
+		e.encodeStringRaw(k.s)
+		return
+	}
+	e.Write(integerPrefix)
+	b := appendInt(e.scratch[:0], k.i)
+	e.Write(append(b, ';'))
+}
+
+// duplicateKeyError is returned when two keys coerce to the same php key
+func duplicateKeyError(v reflect.Value, k phpKey) error {
+	return &UnsupportedValueError{Value: v, Str: fmt.Sprintf("duplicate array key %q", k.String())}
+}
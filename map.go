@@ -1,7 +1,10 @@
 package phpserialize
 
 import (
+	"fmt"
 	"reflect"
+	"sort"
+	"strconv"
 )
 
 func isMap(v reflect.Value) bool {
@@ -9,17 +12,171 @@ func isMap(v reflect.Value) bool {
 }
 
 func (e *Encoder) encodeMap(v reflect.Value) error {
-	mks := v.MapKeys()
-	l := len(mks)
-	e.encodePropsHeader(l)
-	for _, mk := range mks {
-		e.encodeProp(encoderProp{
+	if err := e.enter(v); err != nil {
+		return err
+	}
+	defer e.leave(v)
+	if !e.sortMapKeys && uniqueKeys(v.Type().Key()) {
+		e.encodePropsHeader(v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			mk := iter.Key()
+			err := e.encodeProp(encoderProp{
+				key: func() error {
+					return e.encodeKey(mk, true)
+				},
+				value: iter.Value(),
+			})
+			if err != nil {
+				return wrapPath(err, fmt.Sprintf("[%v]", mk))
+			}
+			if err := e.flush(); err != nil {
+				return err
+			}
+		}
+		return e.encodePropsFinish()
+	}
+	entries := make([]mapEntry, 0, v.Len())
+	seen := make(map[phpKey]struct{}, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		k, err := toPhpKey(iter.Key(), true)
+		if err != nil {
+			return wrapPath(err, fmt.Sprintf("[%v]", iter.Key()))
+		}
+		if _, ok := seen[k]; ok {
+			return duplicateKeyError(v, k)
+		}
+		seen[k] = struct{}{}
+		entries = append(entries, mapEntry{key: k, value: iter.Value()})
+	}
+	if e.sortMapKeys {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].key.less(entries[j].key)
+		})
+	}
+	e.encodePropsHeader(len(entries))
+	for _, entry := range entries {
+		k := entry.key
+		err := e.encodeProp(encoderProp{
 			key: func() error {
-				return e.encodeKey(mk)
+				e.writeKey(k)
+				return nil
 			},
-			value: v.MapIndex(mk),
+			value: entry.value,
 		})
+		if err != nil {
+			return wrapPath(err, "["+k.String()+"]")
+		}
+		if err := e.flush(); err != nil {
+			return err
+		}
+	}
+	return e.encodePropsFinish()
+}
+
+// mapEntry is go map element with its coerced php key
+type mapEntry struct {
+	key   phpKey
+	value reflect.Value
+}
+
+func (d *Decoder) decodeMap(v reflect.Value, n int) error {
+	t := v.Type()
+	keyUnmarshaler := reflect.PtrTo(t.Key()).Implements(unmarshalerType)
+	keyText := reflect.PtrTo(t.Key()).Implements(textUnmarshalerType)
+	switch t.Key().Kind() {
+	case reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+	case reflect.Interface:
+		if t.Key().NumMethod() != 0 {
+			return d.typeError("array", v)
+		}
+	default:
+		if !keyUnmarshaler && !keyText {
+			return d.typeError("array", v)
+		}
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(t, n))
+	}
+	for i := 0; i < n; i++ {
+		start := d.off
+		key, err := d.decodeKey()
+		if err != nil {
+			return err
+		}
+		var kv reflect.Value
+		if keyUnmarshaler {
+			kv = reflect.New(t.Key())
+			if err := kv.Interface().(Unmarshaler).UnmarshalPHP(d.data[start:d.off]); err != nil {
+				return err
+			}
+			kv = kv.Elem()
+		} else if keyText {
+			if kv, err = unmarshalTextKey(t.Key(), key); err != nil {
+				return err
+			}
+		} else {
+			var ok bool
+			if kv, ok = mapKey(t.Key(), key); !ok {
+				return d.typeError(fmt.Sprintf("key %v", key), kv)
+			}
+		}
+		ev := reflect.New(t.Elem()).Elem()
+		if err := d.decodeValue(ev); err != nil {
+			return err
+		}
+		v.SetMapIndex(kv, ev)
 	}
-	e.encodePropsFinish()
 	return nil
 }
+
+// mapKey converts php array key to a value of go map key type
+func mapKey(t reflect.Type, key interface{}) (reflect.Value, bool) {
+	kv := reflect.New(t).Elem()
+	switch k := key.(type) {
+	case int64:
+		switch t.Kind() {
+		case reflect.String:
+			kv.SetString(strconv.FormatInt(k, 10))
+			return kv, true
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if !kv.OverflowInt(k) {
+				kv.SetInt(k)
+				return kv, true
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if k >= 0 && !kv.OverflowUint(uint64(k)) {
+				kv.SetUint(uint64(k))
+				return kv, true
+			}
+		case reflect.Interface:
+			kv.Set(reflect.ValueOf(k))
+			return kv, true
+		}
+	case string:
+		switch t.Kind() {
+		case reflect.String:
+			kv.SetString(k)
+			return kv, true
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, err := strconv.ParseInt(k, 10, 64)
+			if err == nil && !kv.OverflowInt(i) {
+				kv.SetInt(i)
+				return kv, true
+			}
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			u, err := strconv.ParseUint(k, 10, 64)
+			if err == nil && !kv.OverflowUint(u) {
+				kv.SetUint(u)
+				return kv, true
+			}
+		case reflect.Interface:
+			kv.Set(reflect.ValueOf(k))
+			return kv, true
+		}
+	}
+	return kv, false
+}
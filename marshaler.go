@@ -1,21 +1,142 @@
 package phpserialize
 
-import "reflect"
+import (
+	"errors"
+	"reflect"
+)
 
 type Marshaler interface {
 	MarshalPHP() ([]byte, error)
 }
 
+// AppendMarshaler is implemented by types which append serialized
+// representation of themselves to dst and return the extended slice,
+// it is preferred over Marshaler when a type implements both
+type AppendMarshaler interface {
+	AppendPHP(dst []byte) ([]byte, error)
+}
+
+// Unmarshaler is implemented by types which decode serialized
+// representation of themselves, UnmarshalPHP receives raw bytes of
+// exactly one serialized value. N; is not passed to UnmarshalPHP,
+// it sets the value to nil or zero instead
+type Unmarshaler interface {
+	UnmarshalPHP([]byte) error
+}
+
 var (
-	marshalerType = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	marshalerType       = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	appendMarshalerType = reflect.TypeOf((*AppendMarshaler)(nil)).Elem()
+	unmarshalerType     = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
 )
 
 func (e *Encoder) encodeMarshaler(v reflect.Value) error {
 	marshaler := v.Interface().(Marshaler)
 	b, err := marshaler.MarshalPHP()
 	if err != nil {
-		return err
+		return &MarshalerError{Type: v.Type(), Err: err}
+	}
+	if e.validate {
+		if err := validValue(b); err != nil {
+			return &MarshalerError{Type: v.Type(), Err: err}
+		}
 	}
+	e.writeMarshaled(b)
+	return nil
+}
+
+// encodeAppendMarshaler lets v append itself to unused capacity
+// of the buffer so that nothing is allocated when it fits
+func (e *Encoder) encodeAppendMarshaler(v reflect.Value) error {
+	marshaler := v.Interface().(AppendMarshaler)
+	buf := e.Bytes()
+	b, err := marshaler.AppendPHP(buf[len(buf):])
+	if err != nil {
+		return &MarshalerError{Type: v.Type(), Err: err, method: "AppendPHP"}
+	}
+	if e.validate {
+		if err := validValue(b); err != nil {
+			return &MarshalerError{Type: v.Type(), Err: err, method: "AppendPHP"}
+		}
+	}
+	e.writeMarshaled(b)
+	return nil
+}
+
+// writeMarshaled writes output of marshaler, values nested in it are
+// numbered too but they are only counted when a back reference needs it
+func (e *Encoder) writeMarshaled(b []byte) {
+	start := e.Len()
 	e.Write(b)
+	if len(b) == 0 || b[0] != 'a' && b[0] != 'O' {
+		// scalars are cheap to count, they are scanned by length
+		e.state.n += countValues(b) - 1
+		return
+	}
+	e.state.countLater(start, e.Len())
+}
+
+// validValue checks that data holds exactly one serialized value
+func validValue(data []byte) error {
+	n, err := scanValue(data, 0)
+	if err != nil {
+		return err
+	}
+	if n != len(data) {
+		return errors.New("invalid data after serialized value")
+	}
 	return nil
 }
+
+// implementsIndirect reports whether t or pointers it leads to implement it
+func implementsIndirect(t, it reflect.Type) bool {
+	for {
+		if t.Implements(it) || reflect.PtrTo(t).Implements(it) {
+			return true
+		}
+		if t.Kind() != reflect.Ptr {
+			return false
+		}
+		t = t.Elem()
+	}
+}
+
+// indirectTo walks down v allocating pointers until it gets
+// to a value implementing it, nil is returned if there is none
+func indirectTo(v reflect.Value, it reflect.Type) interface{} {
+	if !implementsIndirect(v.Type(), it) {
+		return nil
+	}
+	for {
+		if v.Type().Implements(it) && !isNil(v) {
+			return v.Interface()
+		}
+		if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(it) {
+			return v.Addr().Interface()
+		}
+		if v.Kind() != reflect.Ptr {
+			return nil
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+}
+
+// indirectUnmarshaler returns Unmarshaler v leads to or nil
+func indirectUnmarshaler(v reflect.Value) Unmarshaler {
+	u, _ := indirectTo(v, unmarshalerType).(Unmarshaler)
+	return u
+}
+
+func (d *Decoder) decodeUnmarshaler(v reflect.Value, u Unmarshaler) error {
+	start, slot := d.off, len(d.slots)
+	if err := d.skip(); err != nil {
+		return err
+	}
+	if slot < len(d.slots) {
+		d.slots[slot].v = v
+	}
+	return u.UnmarshalPHP(d.data[start:d.off])
+}
@@ -19,3 +19,16 @@ func (e *Encoder) encodeNil(v reflect.Value) error {
 	e.WriteString("N;")
 	return nil
 }
+
+func (d *Decoder) decodeNil(v reflect.Value) error {
+	d.off++
+	if err := d.expect(';'); err != nil {
+		return err
+	}
+	v = indirect(v, true)
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr, reflect.Map, reflect.Slice:
+		v.Set(reflect.Zero(v.Type()))
+	}
+	return nil
+}
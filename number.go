@@ -0,0 +1,134 @@
+package phpserialize
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"strconv"
+)
+
+// BigEncoding selects how numbers which don't fit php integer are written
+type BigEncoding int
+
+const (
+	// BigFloat writes such numbers as floats losing precision, the default
+	BigFloat BigEncoding = iota
+	// BigString writes such numbers as strings keeping all digits
+	BigString
+)
+
+var (
+	bigIntType   = reflect.TypeOf(big.Int{})
+	bigFloatType = reflect.TypeOf(big.Float{})
+	bigRatType   = reflect.TypeOf(big.Rat{})
+	numberType   = reflect.TypeOf(json.Number(""))
+)
+
+// isNumberType reports whether t is big.Int, big.Float, big.Rat or json.Number
+func isNumberType(t reflect.Type) bool {
+	return t == bigIntType || t == bigFloatType || t == bigRatType || t == numberType
+}
+
+// encodeNumber writes integral numbers fitting int64 as php integers,
+// others as floats or strings depending on encoder settings
+func (e *Encoder) encodeNumber(v reflect.Value) error {
+	if v.Type() == numberType {
+		return e.encodeJSONNumber(v)
+	}
+	switch n := addressable(v).Addr().Interface().(type) {
+	case *big.Int:
+		if n.IsInt64() {
+			e.writeInt(n.Int64())
+			return nil
+		}
+		f, _ := new(big.Float).SetInt(n).Float64()
+		e.writeBig(f, n.String())
+	case *big.Rat:
+		if n.IsInt() && n.Num().IsInt64() {
+			e.writeInt(n.Num().Int64())
+			return nil
+		}
+		f, _ := n.Float64()
+		e.writeBig(f, n.RatString())
+	case *big.Float:
+		if n.IsInt() {
+			if i, acc := n.Int64(); acc == big.Exact {
+				e.writeInt(i)
+				return nil
+			}
+		}
+		f, _ := n.Float64()
+		e.writeBig(f, n.Text('g', -1))
+	}
+	return nil
+}
+
+func (e *Encoder) encodeJSONNumber(v reflect.Value) error {
+	s := v.String()
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		e.writeInt(i)
+		return nil
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !isRangeError(err) {
+		return &UnsupportedValueError{Value: v, Str: strconv.Quote(s) + " is not a number"}
+	}
+	e.writeBig(f, s)
+	return nil
+}
+
+func isRangeError(err error) bool {
+	ne, ok := err.(*strconv.NumError)
+	return ok && ne.Err == strconv.ErrRange
+}
+
+func (e *Encoder) writeInt(i int64) {
+	e.Write(integerPrefix)
+	e.Write(append(appendInt(e.scratch[:0], i), ';'))
+}
+
+// writeBig writes number which is not php integer as float f or string s
+func (e *Encoder) writeBig(f float64, s string) {
+	if e.bigEncoding == BigString {
+		e.encodeStringRaw(s)
+		return
+	}
+	e.WriteString("d:")
+	e.Write(appendFloat(e.scratch[:0], f, 64, e.floatPrecision))
+	e.WriteByte(';')
+}
+
+// decodeNumber decodes php integer or float into big.Int, big.Float,
+// big.Rat or json.Number keeping all digits of integers
+func (d *Decoder) decodeNumber(v reflect.Value) error {
+	var text string
+	if d.data[d.off] == 'i' {
+		b, err := d.readIntegerText()
+		if err != nil {
+			return err
+		}
+		text = string(b)
+	} else {
+		f, err := d.readFloating()
+		if err != nil {
+			return err
+		}
+		text = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+	v = indirect(v, false)
+	ok := true
+	switch n := v.Addr().Interface().(type) {
+	case *big.Int:
+		_, ok = n.SetString(text, 10)
+	case *big.Float:
+		_, ok = n.SetString(text)
+	case *big.Rat:
+		_, ok = n.SetString(text)
+	case *json.Number:
+		*n = json.Number(text)
+	}
+	if !ok {
+		return d.typeError("number "+text, v)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package phpserialize
+
+import "strings"
+
+// encOpts holds encoder settings
+type encOpts struct {
+	sortMapKeys      bool
+	floatPrecision   int
+	structsAsObjects bool
+	tags             string
+	maxDepth         int
+	validate         bool
+	timeEncoding     TimeEncoding
+	timeLayout       string
+	durationEncoding DurationEncoding
+	bigEncoding      BigEncoding
+	bufferSize       int
+}
+
+// EncoderOption configures encoder created with NewEncoder or used by MarshalWithOptions
+type EncoderOption func(*encOpts)
+
+// SortMapKeys makes encoder write go map keys in order php ksort
+// would put them, integers numerically first and then strings
+func SortMapKeys(sort bool) EncoderOption {
+	return func(o *encOpts) {
+		o.sortMapKeys = sort
+	}
+}
+
+// FloatPrecision sets number of significant digits floats are written with,
+// see Encoder.SetFloatPrecision
+func FloatPrecision(precision int) EncoderOption {
+	return func(o *encOpts) {
+		o.floatPrecision = precision
+	}
+}
+
+// StructsAsObjects makes encoder write structs which are not ClassNamer
+// as objects of stdClass instead of arrays
+func StructsAsObjects(objects bool) EncoderOption {
+	return func(o *encOpts) {
+		o.structsAsObjects = objects
+	}
+}
+
+// TagNames sets struct tags field names and options are read from, first
+// tag present on a field wins. Default is php then json
+func TagNames(names ...string) EncoderOption {
+	return func(o *encOpts) {
+		o.tags = strings.Join(names, ",")
+	}
+}
+
+// MaxDepth limits nesting of arrays and objects, 0 means default
+// limit of 10000 and negative depth means no limit
+func MaxDepth(depth int) EncoderOption {
+	return func(o *encOpts) {
+		o.maxDepth = depth
+	}
+}
+
+// ValidateMarshalers makes encoder check that output of Marshaler
+// is exactly one well-formed serialized value before writing it
+func ValidateMarshalers(validate bool) EncoderOption {
+	return func(o *encOpts) {
+		o.validate = validate
+	}
+}
+
+// TimeFormat selects how time.Time values are written, as RFC 3339
+// string by default, unix timestamp or php DateTime object
+func TimeFormat(f TimeEncoding) EncoderOption {
+	return func(o *encOpts) {
+		o.timeEncoding = f
+	}
+}
+
+// TimeLayout makes encoder write time.Time values as strings
+// formatted with layout, see time.Time.Format
+func TimeLayout(layout string) EncoderOption {
+	return func(o *encOpts) {
+		o.timeEncoding = TimeText
+		o.timeLayout = layout
+	}
+}
+
+// DurationFormat selects how time.Duration values are written,
+// as integer number of nanoseconds by default
+func DurationFormat(f DurationEncoding) EncoderOption {
+	return func(o *encOpts) {
+		o.durationEncoding = f
+	}
+}
+
+// BigNumbers selects how big.Int, big.Float, big.Rat and json.Number
+// values which are not php integers are written, as floats by default
+func BigNumbers(f BigEncoding) EncoderOption {
+	return func(o *encOpts) {
+		o.bigEncoding = f
+	}
+}
+
+// BufferSize makes encoder created with NewEncoder write output to its
+// writer whenever more than size bytes are buffered instead of once the
+// whole value is encoded, so that large values are not kept in memory
+func BufferSize(size int) EncoderOption {
+	return func(o *encOpts) {
+		o.bufferSize = size
+	}
+}
@@ -0,0 +1,292 @@
+package phpserialize
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Parser reads php serialized values one after another and is meant for
+// code implementing Unmarshaler, such as one generated by phpserialize-gen.
+// Values are decoded the way Unmarshal does, except for references which
+// can't be resolved in part of serialized data and are refused
+type Parser struct {
+	d Decoder
+}
+
+// NewParser creates parser reading data
+func NewParser(data []byte) *Parser {
+	return &Parser{d: Decoder{data: data, detached: true}}
+}
+
+var (
+	intTypes = map[int]reflect.Type{
+		0:  reflect.TypeOf(int(0)),
+		8:  reflect.TypeOf(int8(0)),
+		16: reflect.TypeOf(int16(0)),
+		32: reflect.TypeOf(int32(0)),
+		64: reflect.TypeOf(int64(0)),
+	}
+	uintTypes = map[int]reflect.Type{
+		0:  reflect.TypeOf(uint(0)),
+		8:  reflect.TypeOf(uint8(0)),
+		16: reflect.TypeOf(uint16(0)),
+		32: reflect.TypeOf(uint32(0)),
+		64: reflect.TypeOf(uint64(0)),
+	}
+	float32Type = reflect.TypeOf(float32(0))
+	float64Type = reflect.TypeOf(float64(0))
+	boolType    = reflect.TypeOf(false)
+	stringType  = reflect.TypeOf("")
+)
+
+// peek returns type character of the next value
+func (p *Parser) peek() (byte, error) {
+	d := &p.d
+	if d.off >= len(d.data) {
+		return 0, errUnexpectedEnd
+	}
+	switch c := d.data[d.off]; c {
+	case 'r', 'R':
+		n, err := d.readRef()
+		if err != nil {
+			return 0, err
+		}
+		return 0, d.errorf("reference %d can not be resolved in part of serialized data", n)
+	default:
+		return c, nil
+	}
+}
+
+// expect checks that the next value is of type c, other values are
+// skipped and reported with UnmarshalTypeError for type t
+func (p *Parser) expect(c byte, t reflect.Type) error {
+	next, err := p.peek()
+	if err != nil || next == c {
+		return err
+	}
+	if err := p.d.skip(); err != nil {
+		return err
+	}
+	return p.d.typeError(tokenName(next), reflect.Zero(t))
+}
+
+// Nil consumes N; and reports true if it is the next value, Unmarshal
+// sets pointers and slices to nil on it and leaves other values alone
+func (p *Parser) Nil() bool {
+	d := &p.d
+	if d.off+1 < len(d.data) && d.data[d.off] == 'N' && d.data[d.off+1] == ';' {
+		d.off += 2
+		return true
+	}
+	return false
+}
+
+// ReadBool reads php bool
+func (p *Parser) ReadBool() (bool, error) {
+	if err := p.expect('b', boolType); err != nil {
+		return false, err
+	}
+	return p.d.readBool()
+}
+
+// ReadInt reads php integer which has to fit in signed integer of bitSize
+// bits, bitSize 0 stands for int like it does for strconv.ParseInt
+func (p *Parser) ReadInt(bitSize int) (int64, error) {
+	t := intTypes[bitSize]
+	if err := p.expect('i', t); err != nil {
+		return 0, err
+	}
+	b, err := p.d.readIntegerText()
+	if err != nil {
+		return 0, err
+	}
+	i, err := strconv.ParseInt(string(b), 10, bitSize)
+	if err != nil {
+		return 0, p.d.typeError("integer "+string(b), reflect.Zero(t))
+	}
+	return i, nil
+}
+
+// ReadUint reads php integer which has to fit in unsigned integer of bitSize
+// bits, bitSize 0 stands for uint like it does for strconv.ParseUint
+func (p *Parser) ReadUint(bitSize int) (uint64, error) {
+	t := uintTypes[bitSize]
+	if err := p.expect('i', t); err != nil {
+		return 0, err
+	}
+	b, err := p.d.readIntegerText()
+	if err != nil {
+		return 0, err
+	}
+	// negative zero is zero as it is for Unmarshal
+	if i, err := strconv.ParseInt(string(b), 10, 64); err == nil && i == 0 {
+		return 0, nil
+	}
+	u, err := strconv.ParseUint(string(b), 10, bitSize)
+	if err != nil {
+		return 0, p.d.typeError("integer "+string(b), reflect.Zero(t))
+	}
+	return u, nil
+}
+
+// ReadFloat reads php float or integer, bitSize is 32 for float32 values
+func (p *Parser) ReadFloat(bitSize int) (float64, error) {
+	t := float64Type
+	if bitSize == 32 {
+		t = float32Type
+	}
+	c, err := p.peek()
+	if err != nil {
+		return 0, err
+	}
+	if c == 'i' {
+		b, err := p.d.readIntegerText()
+		if err != nil {
+			return 0, err
+		}
+		f, _ := strconv.ParseFloat(string(b), 64)
+		return f, nil
+	}
+	if err := p.expect('d', t); err != nil {
+		return 0, err
+	}
+	return p.d.readFloating()
+}
+
+// ReadString reads php string
+func (p *Parser) ReadString() (string, error) {
+	if err := p.expect('s', stringType); err != nil {
+		return "", err
+	}
+	b, err := p.d.readString()
+	return string(b), err
+}
+
+// ReadBytes reads php string or array of integers into byte slice
+func (p *Parser) ReadBytes() ([]byte, error) {
+	c, err := p.peek()
+	if err != nil {
+		return nil, err
+	}
+	if c == 's' {
+		b, err := p.d.readString()
+		return append([]byte(nil), b...), err
+	}
+	var b []byte
+	err = p.d.decodeValue(reflect.ValueOf(&b).Elem())
+	return b, err
+}
+
+// ReadHeader reads start of php array or object and returns number
+// of key value pairs it holds, they are followed by ReadEnd
+func (p *Parser) ReadHeader() (int, error) {
+	c, err := p.peek()
+	if err != nil {
+		return 0, err
+	}
+	if c != 'a' && c != 'O' {
+		return 0, p.expect('a', orderedArrayType)
+	}
+	if err := p.d.enter(); err != nil {
+		return 0, err
+	}
+	if c == 'O' {
+		_, n, err := p.d.readObjectHeader()
+		return n, err
+	}
+	if err := p.d.expectPrefix('a'); err != nil {
+		return 0, err
+	}
+	n, err := p.d.readLen(':')
+	if err != nil {
+		return 0, err
+	}
+	return n, p.d.expect('{')
+}
+
+// ReadKey reads array key, integer keys are returned formatted
+func (p *Parser) ReadKey() (string, error) {
+	key, err := p.d.decodeKey()
+	if err != nil {
+		return "", err
+	}
+	if i, ok := key.(int64); ok {
+		return strconv.FormatInt(i, 10), nil
+	}
+	return key.(string), nil
+}
+
+// ReadIndex reads array key which has to be an index of array of n elements
+func (p *Parser) ReadIndex(n int) (int, error) {
+	key, err := p.d.decodeKey()
+	if err != nil {
+		return 0, err
+	}
+	i, ok := key.(int64)
+	if !ok || i < 0 || i >= int64(n) {
+		return 0, p.d.typeError(fmt.Sprintf("array key %#v", key), reflect.Zero(orderedArrayType))
+	}
+	return int(i), nil
+}
+
+// ReadEnd reads end of array or object
+func (p *Parser) ReadEnd() error {
+	if err := p.d.expect('}'); err != nil {
+		return err
+	}
+	p.d.leave()
+	return nil
+}
+
+// Skip skips the next value
+func (p *Parser) Skip() error {
+	if _, err := p.peek(); err != nil {
+		return err
+	}
+	return p.d.skip()
+}
+
+// Decode decodes the next value into value pointed to by v like Unmarshal does
+func (p *Parser) Decode(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return &InvalidUnmarshalError{reflect.TypeOf(v)}
+	}
+	if _, err := p.peek(); err != nil {
+		return err
+	}
+	return p.d.decodeValue(rv)
+}
+
+// Finish checks that all data was read
+func (p *Parser) Finish() error {
+	d := &p.d
+	if d.off < len(d.data) {
+		return d.errorf("invalid character %q after top-level value", d.data[d.off])
+	}
+	return nil
+}
+
+// MatchField returns index of struct field the property key refers to
+// or -1, keys are serialized property names of fields and names are
+// the names without visibility prefix. Fields are matched the way
+// Unmarshal does, exactly first and then ignoring visibility and case
+func MatchField(key string, keys, names []string) int {
+	for i := range keys {
+		if keys[i] == key {
+			return i
+		}
+	}
+	name, fold := unmangle(key), -1
+	for i := range names {
+		if names[i] == name {
+			return i
+		}
+		if fold < 0 && strings.EqualFold(names[i], name) {
+			fold = i
+		}
+	}
+	return fold
+}
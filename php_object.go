@@ -1,6 +1,8 @@
 package phpserialize
 
 import (
+	"bytes"
+	"fmt"
 	"reflect"
 )
 
@@ -10,20 +12,102 @@ type PhpObject struct {
 }
 
 func isPhpObject(v reflect.Value) bool {
-	_, ok := v.Interface().(PhpObject)
-	return ok
+	return v.Type() == phpObjectType
 }
 
 func (e *Encoder) encodePhpObject(v reflect.Value) error {
 	phpObject := v.Interface().(PhpObject)
-	e.WriteString("O:")
-	e.Write(appendInt(e.scratch[:0], int64(len(phpObject.Name))))
-	prefixAt := e.Len()
-	if err := e.encodeValue(reflect.ValueOf(phpObject.PhpVars)); err != nil {
+	// vars are encoded as array and its type prefix is cut out
+	// afterwards, nothing is flushed until then
+	e.hold++
+	defer func() { e.hold-- }()
+	e.encodeObjectName(phpObject.Name)
+	start := e.Len()
+	if err := e.encodeValue(reflect.ValueOf(&phpObject.PhpVars).Elem()); err != nil {
 		return err
 	}
-	// replace prefix form vars with :
-	e.Bytes()[prefixAt] = ':'
-	e.WriteByte('}')
+	// offsets of uncounted outputs change once prefix is cut out
+	e.state.count(e.Bytes())
+	b := e.Bytes()
+	if !bytes.HasPrefix(b[start:], []byte("a:")) {
+		return &UnsupportedValueError{Value: v, Str: fmt.Sprintf("%v as object properties", phpObject.PhpVars)}
+	}
+	copy(b[start:], b[start+2:])
+	e.Truncate(len(b) - 2)
 	return nil
 }
+
+// encodeObjectName writes object prefix up to properties count
+func (e *Encoder) encodeObjectName(name string) {
+	e.WriteString("O:")
+	e.Write(appendInt(e.scratch[:0], int64(len(name))))
+	e.WriteString(`:"`)
+	e.WriteString(name)
+	e.WriteString(`":`)
+}
+
+func (e *Encoder) encodeObjectHeader(name string, l int) {
+	e.encodeObjectName(name)
+	e.Write(appendInt(e.scratch[:0], int64(l)))
+	e.WriteString(":{")
+}
+
+var phpObjectType = reflect.TypeOf(PhpObject{})
+
+func (d *Decoder) readObjectHeader() (string, int, error) {
+	if err := d.expectPrefix('O'); err != nil {
+		return "", 0, err
+	}
+	l, err := d.readLen(':')
+	if err != nil {
+		return "", 0, err
+	}
+	name, err := d.readQuoted(l)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := d.expect(':'); err != nil {
+		return "", 0, err
+	}
+	n, err := d.readLen(':')
+	if err != nil {
+		return "", 0, err
+	}
+	return string(name), n, d.expect('{')
+}
+
+func (d *Decoder) decodeObject(v reflect.Value) error {
+	if err := d.enter(); err != nil {
+		return err
+	}
+	defer d.leave()
+	name, n, err := d.readObjectHeader()
+	if err != nil {
+		return err
+	}
+	v = indirect(v, false)
+	switch {
+	case isPhpObject(v):
+		vars, err := d.readOrderedArray(n)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(PhpObject{Name: name, PhpVars: vars}))
+	case isEmptyInterface(v):
+		if t, ok := registeredClass(name); ok {
+			pv := reflect.New(t)
+			v.Set(pv)
+			err = d.decodeProps(pv.Elem(), n)
+			break
+		}
+		err = d.decodeValueObject(v, name, n)
+	case isObject(v):
+		err = d.decodeValueObject(v, name, n)
+	default:
+		err = d.decodeProps(v, n)
+	}
+	if err != nil {
+		return err
+	}
+	return d.expect('}')
+}
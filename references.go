@@ -0,0 +1,262 @@
+package phpserialize
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// encRefs numbers values the way php serialize does so that
+// values reached through the same pointer can be written as references
+type encRefs struct {
+	n    int
+	ptrs map[ptrKey]int
+	// uncounted holds buffer offsets of marshaler outputs whose nested
+	// values are not counted in n yet, they are only scanned once a
+	// back reference needs slot numbers
+	uncounted []span
+	// unfixed holds pointers remembered while there were uncounted
+	// outputs, their slots lack values nested in those outputs
+	unfixed []unfixedPtr
+}
+
+// span is a part of encoder buffer
+type span struct {
+	start, end int
+}
+
+type unfixedPtr struct {
+	key ptrKey
+	// uncounted is number of uncounted outputs written before the pointer
+	uncounted int
+}
+
+type ptrKey struct {
+	ptr uintptr
+	typ reflect.Type
+}
+
+func (r *encRefs) reset() {
+	r.n = 0
+	for k := range r.ptrs {
+		delete(r.ptrs, k)
+	}
+	r.uncounted = r.uncounted[:0]
+	r.unfixed = r.unfixed[:0]
+}
+
+// countLater remembers marshaler output written at buf[start:end]
+// so that its nested values are counted when slots are needed
+func (r *encRefs) countLater(start, end int) {
+	r.uncounted = append(r.uncounted, span{start, end})
+}
+
+// count adds values nested in uncounted outputs of buf to n and
+// to slots of pointers remembered after them
+func (r *encRefs) count(buf []byte) {
+	if len(r.uncounted) == 0 {
+		return
+	}
+	nested, fixed := 0, 0
+	for i, s := range r.uncounted {
+		for ; fixed < len(r.unfixed) && r.unfixed[fixed].uncounted == i; fixed++ {
+			r.ptrs[r.unfixed[fixed].key] += nested
+		}
+		nested += countValues(buf[s.start:s.end]) - 1
+	}
+	for ; fixed < len(r.unfixed); fixed++ {
+		r.ptrs[r.unfixed[fixed].key] += nested
+	}
+	r.n += nested
+	r.uncounted = r.uncounted[:0]
+	r.unfixed = r.unfixed[:0]
+}
+
+// encodeRef writes back reference if value pointed to by v was
+// already written, otherwise remembers its position
+func (e *Encoder) encodeRef(v reflect.Value) bool {
+	if v.Type().Elem().Size() == 0 {
+		return false
+	}
+	key := ptrKey{v.Pointer(), v.Type()}
+	slot, ok := e.state.ptrs[key]
+	if !ok {
+		if e.state.ptrs == nil {
+			e.state.ptrs = map[ptrKey]int{}
+		}
+		e.state.ptrs[key] = e.state.n
+		if len(e.state.uncounted) > 0 {
+			e.state.unfixed = append(e.state.unfixed, unfixedPtr{key, len(e.state.uncounted)})
+		}
+		return false
+	}
+	e.state.count(e.Bytes())
+	slot = e.state.ptrs[key]
+	if isObjectType(v.Type().Elem()) || (e.structsAsObjects && v.Type().Elem().Kind() == reflect.Struct) {
+		// object references take a slot of their own
+		e.WriteString("r:")
+	} else {
+		e.state.n--
+		e.WriteString("R:")
+	}
+	e.Write(appendInt(e.scratch[:0], int64(slot)))
+	e.WriteByte(';')
+	return true
+}
+
+// isObjectType reports whether values of t are written as php objects
+func isObjectType(t reflect.Type) bool {
+	switch t {
+	case objectType, phpObjectType, customObjectType:
+		return true
+	}
+	return isSerializableType(t) || (t.Kind() == reflect.Struct && isClassNamerType(t))
+}
+
+// decSlot is a value php unserialize keeps track of for references,
+// skipped values are remembered by their offset
+type decSlot struct {
+	v   reflect.Value
+	off int
+}
+
+// replayKey identifies skipped value decoded for reference into type
+type replayKey struct {
+	slot int
+	typ  reflect.Type
+}
+
+// pushSlot records value starting at current offset
+func (d *Decoder) pushSlot(v reflect.Value) {
+	d.values++
+	if !d.scanning && d.replay == 0 {
+		d.slots = append(d.slots, decSlot{v: v, off: d.off})
+	}
+}
+
+// readRef reads r:N; or R:N; and returns N
+func (d *Decoder) readRef() (int, error) {
+	d.off++
+	if err := d.expect(':'); err != nil {
+		return 0, err
+	}
+	start := d.off
+	n, err := d.readLen(';')
+	if err == nil && n == 0 {
+		d.off = start
+		err = d.syntaxError("reference")
+	}
+	return n, err
+}
+
+// encodeReference writes Reference as R:n; which takes no slot
+func (e *Encoder) encodeReference(v reflect.Value) error {
+	ref := v.Interface().(Reference)
+	e.state.count(e.Bytes())
+	if ref.Slot < 1 || ref.Slot >= e.state.n {
+		return &UnsupportedValueError{Value: v, Str: fmt.Sprintf("reference to value %d which is not written before it", ref.Slot)}
+	}
+	e.state.n--
+	e.WriteString("R:")
+	e.Write(appendInt(e.scratch[:0], int64(ref.Slot)))
+	e.WriteByte(';')
+	return nil
+}
+
+func (d *Decoder) decodeRef(v reflect.Value) error {
+	c := d.data[d.off]
+	n, err := d.readRef()
+	if err != nil {
+		return err
+	}
+	if d.detached {
+		return d.errorf("reference %d can not be resolved in part of serialized data", n)
+	}
+	if n > len(d.slots) {
+		return d.errorf("reference %d is out of range", n)
+	}
+	if c == 'R' && isEmptyInterface(v) {
+		// keep the reference so that it is written back as such
+		var value interface{}
+		if err := d.resolveRef(reflect.ValueOf(&value).Elem(), n); err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(Reference{Slot: n, Value: value}))
+		return nil
+	}
+	return d.resolveRef(v, n)
+}
+
+// resolveRef sets v to value of slot n
+func (d *Decoder) resolveRef(v reflect.Value, n int) error {
+	src := d.slots[n-1]
+	if !src.v.IsValid() {
+		// referenced value was skipped, decode it once more and keep the
+		// result as skipped values may reference each other many times
+		key := replayKey{slot: n, typ: v.Type()}
+		if r, ok := d.replayed[key]; ok {
+			v.Set(r)
+			return nil
+		}
+		off := d.off
+		d.off = src.off
+		d.replay++
+		err := d.decodeValue(v)
+		d.replay--
+		d.off = off
+		if err != nil {
+			return err
+		}
+		if d.replayed == nil {
+			d.replayed = make(map[replayKey]reflect.Value)
+		}
+		r := reflect.New(v.Type()).Elem()
+		r.Set(v)
+		d.replayed[key] = r
+		return nil
+	}
+	if !assignRef(v, src.v) {
+		return d.typeError("reference to "+src.v.Type().String(), v)
+	}
+	return nil
+}
+
+// assignRef makes v share value previously decoded into src
+func assignRef(v, src reflect.Value) bool {
+	for isNonNilInterface(src) || (src.Kind() == reflect.Ptr && !src.IsNil() && src.Elem().Kind() == reflect.Interface) {
+		src = src.Elem()
+	}
+	for {
+		switch {
+		case src.Type().AssignableTo(v.Type()):
+			v.Set(src)
+			return true
+		case src.CanAddr() && src.Addr().Type().AssignableTo(v.Type()):
+			v.Set(src.Addr())
+			return true
+		case src.Kind() == reflect.Ptr && !src.IsNil() && src.Elem().Type().AssignableTo(v.Type()):
+			v.Set(src.Elem())
+			return true
+		}
+		if v.Kind() != reflect.Ptr {
+			break
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return false
+}
+
+func isNonNilInterface(v reflect.Value) bool {
+	return v.Kind() == reflect.Interface && !v.IsNil()
+}
+
+// countValues returns number of values php would number in serialized data
+func countValues(data []byte) int {
+	d := Decoder{data: data, scanning: true}
+	if err := d.skip(); err != nil {
+		return 1
+	}
+	return d.values
+}
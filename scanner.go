@@ -0,0 +1,160 @@
+package phpserialize
+
+import "reflect"
+
+// scanValue validates first serialized value in data nested at most
+// maxDepth levels and returns its length
+func scanValue(data []byte, maxDepth int) (int, error) {
+	s := scanner{maxDepth: maxDepth}
+	return s.scan(data)
+}
+
+// scanner validates serialized value which may arrive in parts, when
+// data ends too early scanning resumes after the last complete key or
+// value once there is more of it
+type scanner struct {
+	off      int
+	maxDepth int
+	// left holds numbers of keys and values left in open arrays and objects
+	left []int
+}
+
+func (s *scanner) reset() {
+	s.off = 0
+	s.left = s.left[:0]
+}
+
+// scan continues validating value at the start of data, which has to
+// begin with data passed to previous calls, and returns its length
+func (s *scanner) scan(data []byte) (int, error) {
+	d := Decoder{data: data, off: s.off, scanning: true, maxDepth: s.maxDepth, depth: len(s.left)}
+	for {
+		top := len(s.left) - 1
+		switch {
+		case top >= 0 && s.left[top] == 0:
+			if err := d.expect('}'); err != nil {
+				return 0, err
+			}
+			d.leave()
+			s.left = s.left[:top]
+		case top >= 0 && s.left[top]%2 == 0:
+			if _, err := d.decodeKey(); err != nil {
+				return 0, err
+			}
+			s.left[top]--
+		default:
+			n, err := d.scanToken()
+			if err != nil {
+				return 0, err
+			}
+			if top >= 0 {
+				s.left[top]--
+			}
+			if n >= 0 {
+				s.left = append(s.left, 2*n)
+			}
+		}
+		s.off = d.off
+		if len(s.left) == 0 {
+			return d.off, nil
+		}
+	}
+}
+
+// scanToken consumes scalar value or header of array or object,
+// it returns number of elements of the latter and -1 otherwise
+func (d *Decoder) scanToken() (int, error) {
+	if d.off >= len(d.data) {
+		return 0, errUnexpectedEnd
+	}
+	switch d.data[d.off] {
+	case 'a':
+		if err := d.enter(); err != nil {
+			return 0, err
+		}
+		if err := d.expectPrefix('a'); err != nil {
+			return 0, err
+		}
+		n, err := d.readLen(':')
+		if err != nil {
+			return 0, err
+		}
+		return n, d.expect('{')
+	case 'O':
+		if err := d.enter(); err != nil {
+			return 0, err
+		}
+		_, n, err := d.readObjectHeader()
+		return n, err
+	}
+	return -1, d.skip()
+}
+
+// skip consumes one serialized value without decoding it
+func (d *Decoder) skip() error {
+	if d.off >= len(d.data) {
+		return errUnexpectedEnd
+	}
+	if d.data[d.off] != 'R' {
+		d.pushSlot(reflect.Value{})
+	}
+	switch d.data[d.off] {
+	case 'r', 'R':
+		_, err := d.readRef()
+		return err
+	case 'N':
+		d.off++
+		return d.expect(';')
+	case 'b':
+		_, err := d.readBool()
+		return err
+	case 'i':
+		_, err := d.readIntegerText()
+		return err
+	case 'd':
+		_, err := d.readFloating()
+		return err
+	case 's':
+		_, err := d.readString()
+		return err
+	case 'a':
+		if err := d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+		if err := d.expectPrefix('a'); err != nil {
+			return err
+		}
+		n, err := d.readLen(':')
+		if err != nil {
+			return err
+		}
+		if err := d.expect('{'); err != nil {
+			return err
+		}
+		if err := d.skipProps(n); err != nil {
+			return err
+		}
+		return d.expect('}')
+	case 'E':
+		_, _, err := d.readEnum()
+		return err
+	case 'C':
+		_, _, err := d.readCustom()
+		return err
+	case 'O':
+		if err := d.enter(); err != nil {
+			return err
+		}
+		defer d.leave()
+		_, n, err := d.readObjectHeader()
+		if err != nil {
+			return err
+		}
+		if err := d.skipProps(n); err != nil {
+			return err
+		}
+		return d.expect('}')
+	}
+	return d.syntaxError("value")
+}
@@ -0,0 +1,126 @@
+package phpserialize
+
+import "reflect"
+
+// PHPSerializable is implemented by types which are serialized the way
+// php Serializable interface does, as C:<len>:"Class":<len>:{payload}
+// where payload is opaque data produced by SerializePHP
+type PHPSerializable interface {
+	ClassNamer
+	SerializePHP() ([]byte, error)
+	UnserializePHP([]byte) error
+}
+
+// CustomObject is php object serialized with Serializable interface.
+// Objects of classes which are not registered with RegisterClass
+// are decoded into *CustomObject when decoding into interface{}
+type CustomObject struct {
+	Class string
+	Data  []byte
+}
+
+var (
+	serializableType = reflect.TypeOf((*PHPSerializable)(nil)).Elem()
+	customObjectType = reflect.TypeOf(CustomObject{})
+)
+
+func isSerializableType(t reflect.Type) bool {
+	return t.Implements(serializableType) || reflect.PtrTo(t).Implements(serializableType)
+}
+
+func isSerializable(v reflect.Value) bool {
+	return isSerializableType(v.Type())
+}
+
+func isCustomObject(v reflect.Value) bool {
+	return v.Type() == customObjectType
+}
+
+func (e *Encoder) encodeSerializable(v reflect.Value) error {
+	if !v.Type().Implements(serializableType) {
+		// UnserializePHP has pointer receiver so values are copied
+		v = addressable(v).Addr()
+	}
+	s := v.Interface().(PHPSerializable)
+	b, err := s.SerializePHP()
+	if err != nil {
+		return &MarshalerError{Type: v.Type(), Err: err, method: "SerializePHP"}
+	}
+	e.encodeCustom(s.PHPClassName(), b)
+	return nil
+}
+
+func (e *Encoder) encodeCustomObject(v reflect.Value) error {
+	obj := v.Interface().(CustomObject)
+	e.encodeCustom(obj.Class, obj.Data)
+	return nil
+}
+
+func (e *Encoder) encodeCustom(class string, data []byte) {
+	e.WriteString("C:")
+	e.Write(appendInt(e.scratch[:0], int64(len(class))))
+	e.WriteString(`:"`)
+	e.WriteString(class)
+	e.WriteString(`":`)
+	e.Write(appendInt(e.scratch[:0], int64(len(data))))
+	e.WriteString(":{")
+	e.Write(data)
+	e.WriteByte('}')
+}
+
+func (d *Decoder) readCustom() (string, []byte, error) {
+	if err := d.expectPrefix('C'); err != nil {
+		return "", nil, err
+	}
+	l, err := d.readLen(':')
+	if err != nil {
+		return "", nil, err
+	}
+	class, err := d.readQuoted(l)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := d.expect(':'); err != nil {
+		return "", nil, err
+	}
+	l, err = d.readLen(':')
+	if err != nil {
+		return "", nil, err
+	}
+	if err := d.expect('{'); err != nil {
+		return "", nil, err
+	}
+	if len(d.data)-d.off < l {
+		return "", nil, errUnexpectedEnd
+	}
+	data := d.data[d.off : d.off+l]
+	d.off += l
+	return string(class), data, d.expect('}')
+}
+
+func (d *Decoder) decodeCustom(v reflect.Value) error {
+	class, data, err := d.readCustom()
+	if err != nil {
+		return err
+	}
+	v = indirect(v, false)
+	switch {
+	case isSerializable(v):
+		if !v.Type().Implements(serializableType) {
+			v = v.Addr()
+		}
+		return v.Interface().(PHPSerializable).UnserializePHP(data)
+	case isCustomObject(v):
+		v.Set(reflect.ValueOf(CustomObject{Class: class, Data: append([]byte(nil), data...)}))
+		return nil
+	case isEmptyInterface(v):
+		if t, ok := registeredClass(class); ok && isSerializableType(t) {
+			pv := reflect.New(t)
+			v.Set(pv)
+			return pv.Interface().(PHPSerializable).UnserializePHP(data)
+		}
+		v.Set(reflect.ValueOf(&CustomObject{Class: class, Data: append([]byte(nil), data...)}))
+		return nil
+	}
+	return d.typeError("object of class "+class, v)
+}
@@ -1,6 +1,7 @@
 package phpserialize
 
 import (
+	"encoding"
 	"reflect"
 	"unicode/utf8"
 )
@@ -17,6 +18,15 @@ func isString(v reflect.Value) bool {
 	return v.Kind() == reflect.String
 }
 
+// isBytes reports whether v is a byte slice or array written as php string
+func isBytes(v reflect.Value) bool {
+	return isBytesType(v.Type())
+}
+
+func isBytesType(t reflect.Type) bool {
+	return (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) && t.Elem().Kind() == reflect.Uint8
+}
+
 func asciiPrintNonQuote(b byte) bool {
 	return b >= ' ' && b <= '~' && b != '\\'
 }
@@ -39,3 +49,64 @@ func (e *Encoder) encodeStringRaw(s string) error {
 	e.Write([]byte{'"', ';'})
 	return nil
 }
+
+func (e *Encoder) encodeBytes(v reflect.Value) error {
+	var b []byte
+	switch {
+	case v.Kind() == reflect.Slice:
+		b = v.Bytes()
+	case v.CanAddr():
+		b = v.Slice(0, v.Len()).Bytes()
+	default:
+		b = make([]byte, v.Len())
+		reflect.Copy(reflect.ValueOf(b), v)
+	}
+	e.WriteString("s:")
+	e.Write(appendInt(e.scratch[:0], int64(len(b))))
+	e.WriteString(`:"`)
+	e.Write(b)
+	e.WriteString(`";`)
+	return nil
+}
+
+func (d *Decoder) readString() ([]byte, error) {
+	if err := d.expectPrefix('s'); err != nil {
+		return nil, err
+	}
+	l, err := d.readLen(':')
+	if err != nil {
+		return nil, err
+	}
+	b, err := d.readQuoted(l)
+	if err != nil {
+		return nil, err
+	}
+	return b, d.expect(';')
+}
+
+func (d *Decoder) decodeString(v reflect.Value) error {
+	b, err := d.readString()
+	if err != nil {
+		return err
+	}
+	if u, ok := indirectTo(v, textUnmarshalerType).(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText(b)
+	}
+	v = indirect(v, false)
+	switch {
+	case isString(v):
+		v.SetString(string(b))
+	case isBytes(v) && v.Kind() == reflect.Slice:
+		v.SetBytes(append([]byte(nil), b...))
+	case isBytes(v):
+		n := reflect.Copy(v, reflect.ValueOf(b))
+		for i := n; i < v.Len(); i++ {
+			v.Index(i).SetUint(0)
+		}
+	case isEmptyInterface(v):
+		v.Set(reflect.ValueOf(string(b)))
+	default:
+		return d.typeError("string", v)
+	}
+	return nil
+}
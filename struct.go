@@ -3,6 +3,7 @@ package phpserialize
 import (
 	"fmt"
 	"reflect"
+	"strconv"
 	"strings"
 	"sync"
 )
@@ -20,14 +21,22 @@ func isStruct(v reflect.Value) bool {
 	return v.Kind() == reflect.Struct
 }
 
-func getTag(field *reflect.StructField) (string, []string) {
-	tag := field.Tag.Get("php")
-	if tag == "" {
-		tag = field.Tag.Get("json")
-		if tag == "" {
-			return "", nil
+const defaultTags = "php,json"
+
+// getTag reads first of comma separated tags present on field
+func getTag(field *reflect.StructField, tags string) (string, []string) {
+	if tags == "" {
+		tags = defaultTags
+	}
+	var tag string
+	for _, name := range strings.Split(tags, ",") {
+		if tag = field.Tag.Get(name); tag != "" {
+			break
 		}
 	}
+	if tag == "" {
+		return "", nil
+	}
 	parts := strings.Split(tag, ",")
 	return parts[0], parts[1:]
 }
@@ -50,49 +59,296 @@ func asString(opts []string) bool {
 	return false
 }
 
+// asArray is option making byte slices written as arrays of integers
+func asArray(opts []string) bool {
+	for _, opt := range opts {
+		if opt == "array" {
+			return true
+		}
+	}
+	return false
+}
+
+func protected(opts []string) bool {
+	for _, opt := range opts {
+		if opt == "protected" {
+			return true
+		}
+	}
+	return false
+}
+
+func private(opts []string) bool {
+	for _, opt := range opts {
+		if opt == "private" {
+			return true
+		}
+	}
+	return false
+}
+
+// classless reports whether property is private one of struct without
+// class name, such structs are written either as arrays or as stdClass
+func classless(opts []string, t, owner reflect.Type) bool {
+	if !private(opts) {
+		return false
+	}
+	_, ok := privateClass(t, owner)
+	return !ok
+}
+
+// propertyKey returns property name the way php serializes it, protected
+// and private properties are prefixed with \0*\0 and \0Class\0
+func propertyKey(name string, opts []string, t, owner reflect.Type) string {
+	switch {
+	case protected(opts):
+		return "\x00*\x00" + name
+	case private(opts):
+		class, ok := privateClass(t, owner)
+		if !ok {
+			// structs without class name are only written as stdClass
+			class = stdClass
+		}
+		return "\x00" + class + "\x00" + name
+	}
+	return name
+}
+
+// unmangle strips visibility prefix from property name
+func unmangle(key string) string {
+	if len(key) > 0 && key[0] == 0 {
+		if i := strings.IndexByte(key[1:], 0); i >= 0 {
+			return key[i+2:]
+		}
+	}
+	return key
+}
+
 type field struct {
 	typ        reflect.Type
 	tagged     bool
 	name       string
+	key        string
 	omitEmpty  bool
 	asString   bool
+	asArray    bool
 	index      []int
 	encode     func(e *Encoder, v reflect.Value) error
 	encodedKey string
+	// arrayKey is encodedKey coerced like php does for arrays
+	arrayKey string
+	// classless is set for private properties of structs without
+	// class name, they are not mangled when written as array
+	classless bool
 }
 
 func (e *Encoder) encodeStruct(v reflect.Value) error {
-	fields := cachedTypeFields(v.Type())
+	if err := e.enter(v); err != nil {
+		return err
+	}
+	defer e.leave(v)
+	fields := cachedTypeFields(v.Type(), e.tags)
+	// count fields first so that they are written straight after header
 	var fieldsCount int
-	senc := encoderStatePool.Get()
-	for i := 0; i < len(fields); i++ {
-		fv := v.Field(fields[i].index[0])
-		for _, idx := range fields[i].index[1:] {
-			if fv.Kind() == reflect.Ptr {
-				if fv.IsNil() {
-					fv.Set(reflect.New(fv.Type().Elem()))
-				}
-				fv = fv.Elem()
-			}
-			fv = fv.Field(idx)
+	for i := range fields {
+		if fv, ok := fieldByIndex(v, fields[i].index); ok && !(fields[i].omitEmpty && isEmptyValue(fv)) {
+			fieldsCount++
+		}
+	}
+	class := e.structClassName(v)
+	if class != "" {
+		e.encodeObjectHeader(class, fieldsCount)
+	} else {
+		e.encodePropsHeader(fieldsCount)
+	}
+	for i := range fields {
+		fv, ok := fieldByIndex(v, fields[i].index)
+		if !ok || fields[i].omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		if class != "" {
+			e.WriteString(fields[i].encodedKey)
+		} else {
+			e.WriteString(fields[i].arrayKey)
+		}
+		e.state.n++
+		if err := fields[i].encode(e, fv); err != nil {
+			return wrapPath(err, fields[i].name)
+		}
+		if err := e.flush(); err != nil {
+			return err
+		}
+	}
+	return e.encodePropsFinish()
+}
+
+// structClassName returns class name struct is written as or empty
+// string if it is written as array
+func (e *Encoder) structClassName(v reflect.Value) string {
+	class := className(v)
+	if class == "" && e.structsAsObjects {
+		class = stdClass
+	}
+	return class
+}
+
+func (d *Decoder) decodeStruct(v reflect.Value, n int) error {
+	fields := cachedTypeFields(v.Type(), "")
+	for i := 0; i < n; i++ {
+		key, err := d.decodeKey()
+		if err != nil {
+			return err
+		}
+		name, ok := key.(string)
+		if !ok {
+			name = strconv.FormatInt(key.(int64), 10)
 		}
-		if fields[i].omitEmpty && isEmptyValue(fv) {
+		f := fieldByKey(fields, name)
+		if f == nil {
+			if err := d.skip(); err != nil {
+				return err
+			}
 			continue
 		}
-		fieldsCount++
-		senc.WriteString(fields[i].encodedKey)
-		fields[i].encode(senc, fv)
+		fv, err := fieldByIndexAlloc(v, f.index)
+		if err != nil {
+			return err
+		}
+		d.fields = append(d.fields, f.name)
+		if f.asString {
+			err = d.decodeAsString(fv)
+		} else {
+			err = d.decodeValue(fv)
+		}
+		if err != nil {
+			return err
+		}
+		d.fields = d.fields[:len(d.fields)-1]
 	}
-	e.encodePropsHeader(fieldsCount)
-	e.Write(senc.Bytes())
-	e.encodePropsFinish()
-	encoderStatePool.Put(senc)
 	return nil
 }
 
+// decodeAsString decodes value of field with string option
+func (d *Decoder) decodeAsString(v reflect.Value) error {
+	if d.off >= len(d.data) {
+		return errUnexpectedEnd
+	}
+	switch c := d.data[d.off]; c {
+	case 'r', 'R':
+		return d.decodeValue(v)
+	case 'N':
+		d.pushSlot(v)
+		return d.decodeNil(v)
+	case 's':
+	default:
+		if err := d.skip(); err != nil {
+			return err
+		}
+		return d.typeError(tokenName(c), v)
+	}
+	d.pushSlot(v)
+	b, err := d.readString()
+	if err != nil {
+		return err
+	}
+	v = indirect(v, false)
+	s := string(b)
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+		return nil
+	case reflect.Bool:
+		switch s {
+		case "true", "1":
+			v.SetBool(true)
+			return nil
+		case "false", "0", "":
+			v.SetBool(false)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, 64)
+		if err == nil && !v.OverflowInt(i) {
+			v.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, 64)
+		if err == nil && !v.OverflowUint(u) {
+			v.SetUint(u)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err == nil {
+			v.SetFloat(f)
+			return nil
+		}
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			v.Set(reflect.ValueOf(s))
+			return nil
+		}
+	}
+	return d.typeError("string "+strconv.Quote(s), v)
+}
+
+// fieldByKey finds field by its serialized property name, preferring
+// exact match over match without visibility prefix and case
+func fieldByKey(fields []field, key string) *field {
+	for i := range fields {
+		if fields[i].key == key {
+			return &fields[i]
+		}
+	}
+	name := unmangle(key)
+	var fold *field
+	for i := range fields {
+		if fields[i].name == name {
+			return &fields[i]
+		}
+		if fold == nil && strings.EqualFold(fields[i].name, name) {
+			fold = &fields[i]
+		}
+	}
+	return fold
+}
+
+// fieldByIndex returns nested field of v, it reports false when
+// the field is reached through nil embedded pointer
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v, true
+}
+
+// fieldByIndexAlloc returns nested field of v allocating nil embedded pointers
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, idx := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("phpserialize: cannot set embedded pointer to unexported struct: %v", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v, nil
+}
+
 var fieldCache sync.Map
 
-func typeFields(t reflect.Type) []field {
+func typeFields(t reflect.Type, tags string) []field {
 	current := []field{}
 	next := []field{{typ: t}}
 	visited := map[reflect.Type]bool{}
@@ -123,7 +379,7 @@ func typeFields(t reflect.Type) []field {
 				} else if isUnexported {
 					continue
 				}
-				tag, opts := getTag(&sf)
+				tag, opts := getTag(&sf, tags)
 				if tag == "-" {
 					continue
 				}
@@ -154,8 +410,11 @@ func typeFields(t reflect.Type) []field {
 						typ:       ft,
 						tagged:    tagged,
 						name:      name,
+						key:       propertyKey(name, opts, t, f.typ),
+						classless: classless(opts, t, f.typ),
 						omitEmpty: omitempty(opts),
 						asString:  asString(opts),
+						asArray:   asArray(opts),
 						index:     index,
 					})
 					continue
@@ -178,31 +437,89 @@ func typeFields(t reflect.Type) []field {
 			}
 			typ = typ.Field(i).Type
 		}
-		if fields[i].asString {
+		switch {
+		case fields[i].asString:
 			fields[i].encode = asStringEncoder(typ)
-		} else {
+		case fields[i].asArray && isBytesType(typ):
+			fields[i].encode = func(e *Encoder, v reflect.Value) error {
+				return e.encodeIterable(v)
+			}
+		}
+		if fields[i].encode == nil {
 			fields[i].encode = typeEncoder(typ)
 		}
-		fields[i].encodedKey = encodeStructKey(fields[i].name)
+		fields[i].encodedKey = encodeStructKey(fields[i].key)
+		fields[i].arrayKey = fields[i].encodedKey
+		arrayKey := fields[i].key
+		if fields[i].classless {
+			arrayKey = fields[i].name
+			fields[i].arrayKey = encodeStructKey(arrayKey)
+		}
+		if k := stringKey(arrayKey, true); !k.str {
+			fields[i].arrayKey = "i:" + strconv.FormatInt(k.i, 10) + ";"
+		}
 	}
 	return fields
 }
 
+// privateClass returns class which declares private properties of owner,
+// embedded structs without class name belong to the outer struct t.
+// It reports false when neither of them has class name
+func privateClass(t, owner reflect.Type) (string, bool) {
+	for _, t := range []reflect.Type{owner, t} {
+		if isClassNamerType(t) {
+			return reflect.New(t).Interface().(ClassNamer).PHPClassName(), true
+		}
+	}
+	return "", false
+}
+
 func encodeStructKey(name string) string {
 	keyBuf, _ := Marshal(name)
 	return string(keyBuf)
 }
 
 func typeEncoder(t reflect.Type) func(*Encoder, reflect.Value) error {
-	if t.Implements(marshalerType) {
+	if t.Implements(appendMarshalerType) || t.Implements(marshalerType) {
 		return func(e *Encoder, v reflect.Value) error {
-			return e.encodeMarshaler(v)
+			return e.encodeValue(v)
 		}
 	}
-	if t == reflect.TypeOf(PhpObject{}) {
+	if info, ok := registeredEnum(t); ok {
+		return func(e *Encoder, v reflect.Value) error {
+			return e.encodeRegisteredEnum(v, info)
+		}
+	}
+	if isSerializableType(t) || t == timeType || t == durationType || isNumberType(t) || (t.Kind() != reflect.Ptr && t.Kind() != reflect.Interface && isTextMarshalerType(t)) {
+		return func(e *Encoder, v reflect.Value) error {
+			return e.encodeValue(v)
+		}
+	}
+	switch t {
+	case enumType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.encodeEnum(v)
+		}
+	case customObjectType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.encodeCustomObject(v)
+		}
+	case phpObjectType:
 		return func(e *Encoder, v reflect.Value) error {
 			return e.encodePhpObject(v)
 		}
+	case objectType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.encodeObject(v)
+		}
+	case referenceType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.encodeReference(v)
+		}
+	case orderedArrayType:
+		return func(e *Encoder, v reflect.Value) error {
+			return e.encodeOrderedArray(v)
+		}
 	}
 	switch t.Kind() {
 	case reflect.Bool:
@@ -222,6 +539,14 @@ func typeEncoder(t reflect.Type) func(*Encoder, reflect.Value) error {
 			return e.encodeString(v)
 		}
 	case reflect.Slice, reflect.Array:
+		if isBytesType(t) {
+			return func(e *Encoder, v reflect.Value) error {
+				if v.Kind() == reflect.Slice && v.IsNil() {
+					return e.encodeNil(v)
+				}
+				return e.encodeBytes(v)
+			}
+		}
 		return func(e *Encoder, v reflect.Value) error {
 			return e.encodeIterable(v)
 		}
@@ -239,6 +564,9 @@ func typeEncoder(t reflect.Type) func(*Encoder, reflect.Value) error {
 			if v.IsNil() {
 				return e.encodeNil(v)
 			}
+			if e.encodeRef(v) {
+				return nil
+			}
 			return f(e, v.Elem())
 		}
 	case reflect.Interface:
@@ -249,7 +577,9 @@ func typeEncoder(t reflect.Type) func(*Encoder, reflect.Value) error {
 			return e.encodeValue(v.Elem())
 		}
 	}
-	return nil
+	return func(e *Encoder, v reflect.Value) error {
+		return &UnsupportedTypeError{t}
+	}
 }
 
 func asStringEncoder(t reflect.Type) func(e *Encoder, v reflect.Value) error {
@@ -313,10 +643,10 @@ func asStringEncoder(t reflect.Type) func(e *Encoder, v reflect.Value) error {
 			if t.Kind() == reflect.Float64 {
 				bitSize = 64
 			}
-			b := appendFloat(e.scratch[:0], v.Float(), bitSize)
+			b := appendFloat(e.scratch[:0], v.Float(), bitSize, e.floatPrecision)
 			lb := appendInt(e.scratch[len(b):len(b)], int64(len(b)))
 			e.WriteString(`s:`)
-			e.Write(e.scratch[len(b):len(lb)])
+			e.Write(e.scratch[len(b) : len(b)+len(lb)])
 			e.WriteString(`:"`)
 			e.Write(e.scratch[:len(b)])
 			e.WriteString(`";`)
@@ -342,10 +672,16 @@ func asStringEncoder(t reflect.Type) func(e *Encoder, v reflect.Value) error {
 	return nil
 }
 
-func cachedTypeFields(t reflect.Type) []field {
-	if f, ok := fieldCache.Load(t); ok {
+type fieldCacheKey struct {
+	typ  reflect.Type
+	tags string
+}
+
+func cachedTypeFields(t reflect.Type, tags string) []field {
+	key := fieldCacheKey{t, tags}
+	if f, ok := fieldCache.Load(key); ok {
 		return f.([]field)
 	}
-	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	f, _ := fieldCache.LoadOrStore(key, typeFields(t, tags))
 	return f.([]field)
 }
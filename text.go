@@ -0,0 +1,55 @@
+package phpserialize
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	fmtStringerType     = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// isTextMarshalerType reports whether values of t are written as php strings
+func isTextMarshalerType(t reflect.Type) bool {
+	return t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType)
+}
+
+// textMarshaler returns TextMarshaler implemented by v or its address
+func textMarshaler(v reflect.Value) (encoding.TextMarshaler, bool) {
+	if v.Type().Implements(textMarshalerType) && !isNil(v) {
+		return v.Interface().(encoding.TextMarshaler), true
+	}
+	if v.CanAddr() && reflect.PtrTo(v.Type()).Implements(textMarshalerType) {
+		return v.Addr().Interface().(encoding.TextMarshaler), true
+	}
+	return nil, false
+}
+
+func (e *Encoder) encodeTextMarshaler(v reflect.Value, m encoding.TextMarshaler) error {
+	b, err := m.MarshalText()
+	if err != nil {
+		return &MarshalerError{Type: v.Type(), Err: err, method: "MarshalText"}
+	}
+	return e.encodeStringRaw(string(b))
+}
+
+// unmarshalTextKey converts php array key to a value of go map key type
+// implementing TextUnmarshaler
+func unmarshalTextKey(t reflect.Type, key interface{}) (reflect.Value, error) {
+	var text string
+	switch k := key.(type) {
+	case int64:
+		text = strconv.FormatInt(k, 10)
+	case string:
+		text = k
+	}
+	kv := reflect.New(t)
+	if err := kv.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); err != nil {
+		return reflect.Value{}, err
+	}
+	return kv.Elem(), nil
+}
@@ -0,0 +1,247 @@
+package phpserialize
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+// TimeEncoding selects how time.Time values are serialized
+type TimeEncoding int
+
+const (
+	// TimeText writes time as RFC 3339 string, the default
+	TimeText TimeEncoding = iota
+	// TimeUnix writes time as integer unix timestamp
+	TimeUnix
+	// TimeDateTime writes time as php DateTime object
+	TimeDateTime
+	// TimeDateTimeImmutable writes time as php DateTimeImmutable object
+	TimeDateTimeImmutable
+)
+
+// DurationEncoding selects how time.Duration values are serialized
+type DurationEncoding int
+
+const (
+	// DurationNanoseconds writes duration as integer number of nanoseconds, the default
+	DurationNanoseconds DurationEncoding = iota
+	// DurationSeconds writes duration as float number of seconds
+	DurationSeconds
+	// DurationString writes duration as string like "1h30m0s"
+	DurationString
+)
+
+// phpDateLayout is layout of date property of php DateTime objects
+const phpDateLayout = "2006-01-02 15:04:05.000000"
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// isTimeType reports whether t is time.Time or a pointer leading to it
+func isTimeType(t reflect.Type) bool {
+	return derefType(t) == timeType
+}
+
+// isDurationType reports whether t is time.Duration or a pointer leading to it
+func isDurationType(t reflect.Type) bool {
+	return derefType(t) == durationType
+}
+
+func (e *Encoder) encodeTime(v reflect.Value) error {
+	t := v.Interface().(time.Time)
+	switch e.timeEncoding {
+	case TimeUnix:
+		e.Write(integerPrefix)
+		e.Write(append(appendInt(e.scratch[:0], t.Unix()), ';'))
+		return nil
+	case TimeDateTime:
+		return e.encodeDateTime(t, "DateTime")
+	case TimeDateTimeImmutable:
+		return e.encodeDateTime(t, "DateTimeImmutable")
+	}
+	if e.timeLayout != "" {
+		return e.encodeStringRaw(t.Format(e.timeLayout))
+	}
+	b, err := t.MarshalText()
+	if err != nil {
+		return &MarshalerError{Type: v.Type(), Err: err, method: "MarshalText"}
+	}
+	return e.encodeStringRaw(string(b))
+}
+
+// encodeDateTime writes t the way php serializes DateTime objects,
+// zones with IANA names get timezone_type 3 and others 1 with utc offset
+func (e *Encoder) encodeDateTime(t time.Time, class string) error {
+	zone, zoneType := t.Location().String(), int64(3)
+	if zone != "UTC" && !isZoneName(zone) {
+		zone, zoneType = t.Format("-07:00"), 1
+	}
+	e.encodeObjectHeader(class, 3)
+	e.encodeStringRaw("date")
+	e.state.n++
+	e.encodeStringRaw(t.Format(phpDateLayout))
+	e.encodeStringRaw("timezone_type")
+	e.state.n++
+	e.Write(integerPrefix)
+	e.Write(append(appendInt(e.scratch[:0], zoneType), ';'))
+	e.encodeStringRaw("timezone")
+	e.state.n++
+	e.encodeStringRaw(zone)
+	return e.encodePropsFinish()
+}
+
+// isZoneName reports whether zone looks like IANA time zone name
+func isZoneName(zone string) bool {
+	for i := 0; i < len(zone); i++ {
+		if zone[i] == '/' {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *Encoder) encodeDuration(v reflect.Value) error {
+	d := time.Duration(v.Int())
+	switch e.durationEncoding {
+	case DurationSeconds:
+		e.WriteString("d:")
+		e.Write(appendFloat(e.scratch[:0], d.Seconds(), 64, e.floatPrecision))
+		e.WriteByte(';')
+		return nil
+	case DurationString:
+		return e.encodeStringRaw(d.String())
+	}
+	return e.encodeInteger(v)
+}
+
+// decodeTime decodes unix timestamp, string or DateTime object into time.Time
+func (d *Decoder) decodeTime(v reflect.Value) error {
+	v = indirect(v, false)
+	var t time.Time
+	switch d.data[d.off] {
+	case 'i':
+		i, err := d.readInteger()
+		if err != nil {
+			return err
+		}
+		t = time.Unix(i, 0)
+	case 'd':
+		f, err := d.readFloating()
+		if err != nil {
+			return err
+		}
+		sec, frac := math.Modf(f)
+		t = time.Unix(int64(sec), int64(frac*1e9))
+	case 's':
+		b, err := d.readString()
+		if err != nil {
+			return err
+		}
+		if t, err = d.parseTime(string(b)); err != nil {
+			return d.typeError("string "+strconv.Quote(string(b)), v)
+		}
+	case 'O':
+		class, n, err := d.readObjectHeader()
+		if err != nil {
+			return err
+		}
+		props, err := d.readOrderedArray(n)
+		if err != nil {
+			return err
+		}
+		if err := d.expect('}'); err != nil {
+			return err
+		}
+		if t, err = dateTime(props); err != nil {
+			return d.typeError("object of class "+class, v)
+		}
+	default:
+		return d.typeError(tokenName(d.data[d.off]), v)
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// decodeDuration decodes integer number of nanoseconds, float number
+// of seconds or duration string into time.Duration
+func (d *Decoder) decodeDuration(v reflect.Value) error {
+	v = indirect(v, false)
+	var dur time.Duration
+	switch d.data[d.off] {
+	case 'i':
+		i, err := d.readInteger()
+		if err != nil {
+			return err
+		}
+		dur = time.Duration(i)
+	case 'd':
+		f, err := d.readFloating()
+		if err != nil {
+			return err
+		}
+		ns := math.Round(f * float64(time.Second))
+		if math.IsNaN(ns) || ns < math.MinInt64 || ns >= -math.MinInt64 {
+			return d.typeError("float "+strconv.FormatFloat(f, 'g', -1, 64), v)
+		}
+		dur = time.Duration(ns)
+	case 's':
+		b, err := d.readString()
+		if err != nil {
+			return err
+		}
+		if dur, err = time.ParseDuration(string(b)); err != nil {
+			return d.typeError("string "+strconv.Quote(string(b)), v)
+		}
+	default:
+		return d.typeError(tokenName(d.data[d.off]), v)
+	}
+	v.SetInt(int64(dur))
+	return nil
+}
+
+// parseTime parses time string with layout set on decoder or
+// with RFC 3339 and php Y-m-d H:i:s layouts
+func (d *Decoder) parseTime(s string) (time.Time, error) {
+	if d.timeLayout != "" {
+		return time.Parse(d.timeLayout, s)
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		t, err = time.Parse("2006-01-02 15:04:05", s)
+	}
+	return t, err
+}
+
+// dateTime converts properties of php DateTime object to time.Time
+func dateTime(props OrderedArray) (time.Time, error) {
+	date, _ := props.Get("date")
+	zoneType, _ := props.Get("timezone_type")
+	zone, _ := props.Get("timezone")
+	ds, ok := date.(string)
+	zs, zok := zone.(string)
+	if !ok || !zok {
+		return time.Time{}, errors.New("missing date or timezone")
+	}
+	var loc *time.Location
+	switch zoneType {
+	case int64(1):
+		t, err := time.Parse("-07:00", zs)
+		if err != nil {
+			return time.Time{}, err
+		}
+		_, offset := t.Zone()
+		loc = time.FixedZone(zs, offset)
+	default:
+		var err error
+		if loc, err = time.LoadLocation(zs); err != nil {
+			return time.Time{}, err
+		}
+	}
+	// fraction of second is optional when parsing
+	return time.ParseInLocation("2006-01-02 15:04:05", ds, loc)
+}
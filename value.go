@@ -0,0 +1,195 @@
+package phpserialize
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// KeyValue is a single element of php array, Key is either int64 or string
+type KeyValue struct {
+	Key   interface{}
+	Value interface{}
+}
+
+// OrderedArray is php array which keeps order and types of its keys.
+// It is encoded in insertion order and php arrays are decoded into it
+// when decoding into interface{}, R: references in them are decoded
+// into Reference. Lookups are linear.
+type OrderedArray []KeyValue
+
+// Get returns value stored under key
+func (a OrderedArray) Get(key interface{}) (interface{}, bool) {
+	if i := a.index(key); i >= 0 {
+		return a[i].Value, true
+	}
+	return nil, false
+}
+
+// Set replaces value stored under key or appends it at the end
+func (a *OrderedArray) Set(key, value interface{}) {
+	if i := a.index(key); i >= 0 {
+		(*a)[i].Value = value
+		return
+	}
+	*a = append(*a, KeyValue{Key: arrayKey(key), Value: value})
+}
+
+// Delete removes key keeping order of remaining elements
+func (a *OrderedArray) Delete(key interface{}) {
+	if i := a.index(key); i >= 0 {
+		*a = append((*a)[:i], (*a)[i+1:]...)
+	}
+}
+
+func (a OrderedArray) index(key interface{}) int {
+	k, ok := coerceKey(key)
+	if !ok {
+		return -1
+	}
+	for i := range a {
+		if ak, ok := coerceKey(a[i].Key); ok && ak == k {
+			return i
+		}
+	}
+	return -1
+}
+
+// arrayKey coerces key the way php does, keys of go integer,
+// string, bool and float types are converted to int64 or string
+func arrayKey(key interface{}) interface{} {
+	if k, ok := coerceKey(key); ok {
+		return k.value()
+	}
+	return key
+}
+
+// coerceKey returns php key for key, ok is false for keys php
+// arrays can't have
+func coerceKey(key interface{}) (phpKey, bool) {
+	v := reflect.ValueOf(key)
+	if !v.IsValid() {
+		return phpKey{str: true}, true
+	}
+	k, err := toPhpKey(v, true)
+	return k, err == nil
+}
+
+// Object is php object with its class name and properties in serialized order.
+// Php objects are decoded into *Object when decoding into interface{}
+type Object struct {
+	Class      string
+	Properties OrderedArray
+}
+
+// Reference is php reference R:n; decoded into interface{}. Slot is number
+// of the referenced value counted like php does from 1 for the top-level
+// value and Value holds the referenced value. It is written back as R:n;
+type Reference struct {
+	Slot  int
+	Value interface{}
+}
+
+var (
+	orderedArrayType = reflect.TypeOf(OrderedArray(nil))
+	objectType       = reflect.TypeOf(Object{})
+	referenceType    = reflect.TypeOf(Reference{})
+)
+
+func isOrderedArray(v reflect.Value) bool {
+	return v.Type() == orderedArrayType
+}
+
+func isObject(v reflect.Value) bool {
+	return v.Type() == objectType
+}
+
+func isReference(v reflect.Value) bool {
+	return v.Type() == referenceType
+}
+
+func (e *Encoder) encodeOrderedArray(v reflect.Value) error {
+	arr := v.Interface().(OrderedArray)
+	e.encodePropsHeader(len(arr))
+	if err := e.encodeKeyValues(arr, true); err != nil {
+		return err
+	}
+	return e.encodePropsFinish()
+}
+
+func (e *Encoder) encodeObject(v reflect.Value) error {
+	obj := v.Interface().(Object)
+	e.encodeObjectHeader(obj.Class, len(obj.Properties))
+	if err := e.encodeKeyValues(obj.Properties, false); err != nil {
+		return err
+	}
+	return e.encodePropsFinish()
+}
+
+// encodeKeyValues writes elements of array or properties of object,
+// numeric string keys of arrays are written as integers like php does
+func (e *Encoder) encodeKeyValues(arr OrderedArray, array bool) error {
+	v := reflect.ValueOf(arr)
+	if err := e.enter(v); err != nil {
+		return err
+	}
+	defer e.leave(v)
+	var seen map[phpKey]struct{}
+	if len(arr) > 1 {
+		seen = make(map[phpKey]struct{}, len(arr))
+	}
+	for i := range arr {
+		kv := reflect.ValueOf(&arr[i]).Elem()
+		k, err := toPhpKey(kv.Field(0), array)
+		if err != nil {
+			return wrapPath(err, fmt.Sprintf("[%v]", arr[i].Key))
+		}
+		if seen != nil {
+			if _, ok := seen[k]; ok {
+				return duplicateKeyError(reflect.ValueOf(arr), k)
+			}
+			seen[k] = struct{}{}
+		}
+		e.writeKey(k)
+		e.state.n++
+		if err := e.encodeValue(kv.Field(1)); err != nil {
+			return wrapPath(err, fmt.Sprintf("[%v]", arr[i].Key))
+		}
+		if err := e.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readOrderedArray reads n key value pairs preserving their order
+func (d *Decoder) readOrderedArray(n int) (OrderedArray, error) {
+	arr := make(OrderedArray, n)
+	for i := range arr {
+		key, err := d.decodeKey()
+		if err != nil {
+			return nil, err
+		}
+		arr[i].Key = key
+		if err := d.decodeValue(reflect.ValueOf(&arr[i].Value).Elem()); err != nil {
+			return nil, err
+		}
+	}
+	return arr, nil
+}
+
+// decodeValueObject decodes object properties into Object or interface{}
+func (d *Decoder) decodeValueObject(v reflect.Value, class string, n int) error {
+	obj := &Object{Class: class}
+	if isObject(v) {
+		obj = v.Addr().Interface().(*Object)
+		obj.Class = class
+	} else {
+		v.Set(reflect.ValueOf(obj))
+	}
+	props, err := d.readOrderedArray(n)
+	if err != nil {
+		return err
+	}
+	obj.Properties = props
+	return nil
+}